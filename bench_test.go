@@ -0,0 +1,163 @@
+package reflect_walker
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type benchAddress struct {
+	Street string `json:"street"`
+	City   string `json:"city"`
+	Zip    string `json:"zip,omitempty"`
+}
+
+type benchUser struct {
+	ID       int               `json:"id"`
+	Name     string            `json:"name"`
+	Email    string            `json:"email"`
+	Active   bool              `json:"active"`
+	Score    float64           `json:"score"`
+	Created  time.Time         `json:"created"`
+	Tags     []string          `json:"tags"`
+	Labels   map[string]string `json:"labels"`
+	Address  benchAddress      `json:"address"`
+	Previous *benchAddress     `json:"previous"`
+	secret   string
+}
+
+func bench_users(n int) []*benchUser {
+	users := make([]*benchUser, n)
+	for i := range users {
+		users[i] = &benchUser{
+			ID:       i,
+			Name:     "user",
+			Email:    "user@example.com",
+			Active:   i%2 == 0,
+			Score:    float64(i) / 3,
+			Created:  time.Unix(int64(i), 0),
+			Tags:     []string{"a", "b"},
+			Labels:   map[string]string{"env": "prod"},
+			Address:  benchAddress{Street: "main", City: "x"},
+			Previous: &benchAddress{Street: "old", City: "y"},
+			secret:   "s",
+		}
+	}
+	return users
+}
+
+func bench_routine(ctx context.Context, node TreeNode) {
+	if s, err := node.Value().String(); err == nil && s == "user@example.com" {
+		node.Value().Set("***")
+	}
+}
+
+// 每次新建walker，类型计划需要重新生成（struct字段信息仍由包级缓存复用）
+func BenchmarkWalk_struct_new_walker(b *testing.B) {
+	users := bench_users(100)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewTreeWalker(WithRoutine(bench_routine), WithTextMarshalerLeaves()).Walk(ctx, users)
+	}
+}
+
+// 每次清空包级字段缓存并新建walker，所有计划都重新生成，与上面两项对比缓存的效果
+func BenchmarkWalk_struct_uncached(b *testing.B) {
+	users := bench_users(100)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		clear_field_plans()
+		b.StartTimer()
+		NewTreeWalker(WithRoutine(bench_routine), WithTextMarshalerLeaves()).Walk(ctx, users)
+	}
+}
+
+// 清空包级的struct字段缓存
+func clear_field_plans() {
+	fieldPlans.Range(func(key, _ interface{}) bool {
+		fieldPlans.Delete(key)
+		return true
+	})
+}
+
+// 复用walker，命中类型计划缓存
+func BenchmarkWalk_struct_cached(b *testing.B) {
+	users := bench_users(100)
+	ctx := context.Background()
+	walker := NewTreeWalker(WithRoutine(bench_routine), WithTextMarshalerLeaves())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		walker.Walk(ctx, users)
+	}
+}
+
+func BenchmarkWalk_struct_as_map_cached(b *testing.B) {
+	users := bench_users(100)
+	ctx := context.Background()
+	walker := NewTreeWalker(WithRoutine(bench_routine), WithTextMarshalerLeaves(), WithStructAsMap(), WithKeyTransform(CamelCase))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		walker.Walk(ctx, users)
+	}
+}
+
+const benchSize = 10000
+
+func bench_walk(b *testing.B, in interface{}, opts ...WalkOption) {
+	ctx := context.Background()
+	walker := NewTreeWalker(opts...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		walker.Walk(ctx, in)
+	}
+}
+
+func bench_noop(ctx context.Context, node TreeNode) {}
+
+// 按值的类型筛选，不装箱读取值，只改写命中的字符串
+func bench_type_filter(ctx context.Context, node TreeNode) {
+	if node.Value().TypeKind() == reflect.String {
+		if err := node.Value().Set("***"); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func BenchmarkAlloc_map(b *testing.B) {
+	m := make(map[string]interface{}, benchSize)
+	for i := 0; i < benchSize; i++ {
+		m[fmt.Sprintf("key%d", i)] = fmt.Sprintf("value%d", i)
+	}
+	b.Run("no_routine", func(b *testing.B) { bench_walk(b, m) })
+	b.Run("noop_routine", func(b *testing.B) { bench_walk(b, m, WithRoutine(bench_noop)) })
+	b.Run("filter_routine", func(b *testing.B) { bench_walk(b, m, WithRoutine(bench_type_filter)) })
+}
+
+func BenchmarkAlloc_slice(b *testing.B) {
+	s := make([]string, benchSize)
+	for i := range s {
+		s[i] = fmt.Sprintf("value%d", i)
+	}
+	b.Run("no_routine", func(b *testing.B) { bench_walk(b, s) })
+	b.Run("noop_routine", func(b *testing.B) { bench_walk(b, s, WithRoutine(bench_noop)) })
+	b.Run("filter_routine", func(b *testing.B) { bench_walk(b, s, WithRoutine(bench_type_filter)) })
+}
+
+func BenchmarkAlloc_struct(b *testing.B) {
+	users := bench_users(benchSize)
+	b.Run("no_routine", func(b *testing.B) { bench_walk(b, users, WithTextMarshalerLeaves()) })
+	b.Run("noop_routine", func(b *testing.B) { bench_walk(b, users, WithTextMarshalerLeaves(), WithRoutine(bench_noop)) })
+	b.Run("filter_routine", func(b *testing.B) {
+		bench_walk(b, users, WithTextMarshalerLeaves(), WithRoutine(bench_type_filter))
+	})
+}
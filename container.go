@@ -0,0 +1,132 @@
+package reflect_walker
+
+import (
+	"context"
+	"reflect"
+)
+
+type Container_routine func(ctx context.Context, c Container)
+
+// 容器（map、slice、struct、Walkable），其成员全部处理完、重建之前交给Container_routine
+// 根容器、空容器与nil的map、slice同样会交出，用于新增成员，如在每一级注入_version
+// 匿名嵌入展开到上一级的struct不单独交出，nil的struct指针没有容器
+type Container interface {
+	Type() reflect.Type // 容器的类型，指向struct的指针为struct类型
+	Path() Path         // 容器在整个输入中的路径，根容器为空
+	Depth() int         // 容器的嵌套深度，根容器为0，与其成员的TreeNode.Depth()相同
+
+	// 向map、Walkable或输出为map的struct添加键值对，重建时在所有成员之后写入，覆盖同名的key
+	// 原样输出的struct只能设置已有的公有字段，key为字段名或标签名，其他key返回ErrInsertUnsupported
+	Insert(key, value interface{}) error
+	// 在slice末尾追加元素，其他容器返回ErrInsertUnsupported
+	Append(values ...interface{}) error
+}
+
+// 容器的成员处理完后执行，与WithRoutine互不依赖，不需要WithContainerNodes
+func WithContainerRoutine(routines ...Container_routine) WalkOption {
+	return func(tw *walker) {
+		tw.containers = routines
+	}
+}
+
+type containerNode struct {
+	kind  reflect.Kind // 按什么方式插入：Map为键值对，Slice为追加，Struct为设置字段
+	typ   reflect.Type
+	sc    *scope
+	key   treeVariable // 插入的key所在位置的声明类型
+	value treeVariable // 插入的值所在位置的声明类型
+
+	fields  []fieldPlan   // 原样输出的struct的字段
+	inserts []WalkPair    // 新增的键值对
+	appends []interface{} // 追加的slice元素
+	sets    []fieldValue  // 设置的struct字段
+}
+
+type fieldValue struct {
+	index int
+	val   reflect.Value
+}
+
+// 对容器执行Container_routine，没有设置时返回nil
+func (tr *walker) visit_container(ctx context.Context, cn containerNode) *containerNode {
+	if len(tr.containers) == 0 {
+		return nil
+	}
+
+	c := &cn
+	if tr.maxDepth != NoDepthLimit {
+		depth := c.Depth()
+		ctx = context.WithValue(ctx, DepthCtxKey, &depth)
+	}
+	for _, r := range tr.containers {
+		r(ctx, c)
+	}
+	return c
+}
+
+func (cn *containerNode) Type() reflect.Type {
+	return cn.typ
+}
+
+func (cn *containerNode) Path() Path {
+	return cn.sc.path(0)
+}
+
+func (cn *containerNode) Depth() int {
+	return cn.sc.levels()
+}
+
+func (cn *containerNode) Insert(key, value interface{}) error {
+	switch cn.kind {
+	case reflect.Map:
+		k, err := cn.key.fit(key)
+		if err != nil {
+			return err
+		}
+		v, err := cn.value.fit(value)
+		if err != nil {
+			return err
+		}
+		cn.inserts = append(cn.inserts, WalkPair{Key: k, Value: v})
+		return nil
+	case reflect.Struct:
+		name, ok := key.(string)
+		if !ok {
+			return ErrInsertUnsupported
+		}
+		for _, fp := range cn.fields {
+			if !fp.exported || fp.skip || (fp.name != name && fp.field.Name != name) {
+				continue
+			}
+			v, err := convert_value(reflect.ValueOf(value), fp.field.Type)
+			if err != nil {
+				return err
+			}
+			cn.sets = append(cn.sets, fieldValue{index: fp.index, val: v})
+			return nil
+		}
+	}
+	return ErrInsertUnsupported
+}
+
+func (cn *containerNode) Append(values ...interface{}) error {
+	if cn.kind != reflect.Slice {
+		return ErrInsertUnsupported
+	}
+
+	fitted := make([]interface{}, len(values))
+	for i, value := range values {
+		v, err := cn.value.fit(value)
+		if err != nil {
+			return err
+		}
+		fitted[i] = v
+	}
+	cn.appends = append(cn.appends, fitted...)
+	return nil
+}
+
+// 是否有新增的成员
+func (cn *containerNode) changed() bool {
+	return cn != nil && len(cn.inserts)+len(cn.appends)+len(cn.sets) > 0
+}
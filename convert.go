@@ -0,0 +1,470 @@
+package reflect_walker
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 宽松类型转换，按Kind处理，因此对`type Port int`这类具名类型同样有效
+
+// 解开指针与interface，nil返回无效值
+func indirect_value(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func is_bytes(v reflect.Value) bool {
+	return v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8
+}
+
+func to_int64(v reflect.Value) (int64, error) {
+	v = indirect_value(v)
+	if !v.IsValid() {
+		return 0, ErrTypeAssertFailed
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		if u > math.MaxInt64 {
+			return 0, fmt.Errorf("%w: %d overflows int64", ErrOverflow, u)
+		}
+		return int64(u), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || f >= math.MaxInt64 || f < math.MinInt64 {
+			return 0, fmt.Errorf("%w: %g overflows int64", ErrOverflow, f)
+		}
+		if f != math.Trunc(f) {
+			return 0, fmt.Errorf("%w: %g is not an integer", ErrPrecisionLoss, f)
+		}
+		return int64(f), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return 1, nil
+		}
+		return 0, nil
+	case reflect.String:
+		return parse_int64(v.String())
+	case reflect.Slice:
+		if is_bytes(v) {
+			return parse_int64(string(v.Bytes()))
+		}
+	}
+	return 0, ErrTypeAssertFailed
+}
+
+func parse_int64(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err == nil {
+		return n, nil
+	}
+	if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+		return 0, fmt.Errorf("%w: %q overflows int64", ErrOverflow, s)
+	}
+	// 兼容"1e3"、"2.0"这类写法
+	if f, ferr := strconv.ParseFloat(s, 64); ferr == nil {
+		return to_int64(reflect.ValueOf(f))
+	}
+	return 0, fmt.Errorf("%w: %q is not an integer", ErrParseFailed, s)
+}
+
+func to_uint64(v reflect.Value) (uint64, error) {
+	v = indirect_value(v)
+	if !v.IsValid() {
+		return 0, ErrTypeAssertFailed
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := v.Int()
+		if i < 0 {
+			return 0, fmt.Errorf("%w: %d overflows uint64", ErrOverflow, i)
+		}
+		return uint64(i), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || f < 0 || f >= math.MaxUint64 {
+			return 0, fmt.Errorf("%w: %g overflows uint64", ErrOverflow, f)
+		}
+		if f != math.Trunc(f) {
+			return 0, fmt.Errorf("%w: %g is not an integer", ErrPrecisionLoss, f)
+		}
+		return uint64(f), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return 1, nil
+		}
+		return 0, nil
+	case reflect.String:
+		return parse_uint64(v.String())
+	case reflect.Slice:
+		if is_bytes(v) {
+			return parse_uint64(string(v.Bytes()))
+		}
+	}
+	return 0, ErrTypeAssertFailed
+}
+
+func parse_uint64(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err == nil {
+		return n, nil
+	}
+	if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+		return 0, fmt.Errorf("%w: %q overflows uint64", ErrOverflow, s)
+	}
+	if f, ferr := strconv.ParseFloat(s, 64); ferr == nil {
+		return to_uint64(reflect.ValueOf(f))
+	}
+	return 0, fmt.Errorf("%w: %q is not an unsigned integer", ErrParseFailed, s)
+}
+
+func to_float64(v reflect.Value) (float64, error) {
+	v = indirect_value(v)
+	if !v.IsValid() {
+		return 0, ErrTypeAssertFailed
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := v.Int()
+		f := float64(i)
+		// 超过2^53的整数无法被float64精确表示
+		if f >= math.MaxInt64 || int64(f) != i {
+			return 0, fmt.Errorf("%w: %d cannot be represented as float64", ErrPrecisionLoss, i)
+		}
+		return f, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		f := float64(u)
+		if f >= math.MaxUint64 || uint64(f) != u {
+			return 0, fmt.Errorf("%w: %d cannot be represented as float64", ErrPrecisionLoss, u)
+		}
+		return f, nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return 1, nil
+		}
+		return 0, nil
+	case reflect.String:
+		return parse_float64(v.String())
+	case reflect.Slice:
+		if is_bytes(v) {
+			return parse_float64(string(v.Bytes()))
+		}
+	}
+	return 0, ErrTypeAssertFailed
+}
+
+func parse_float64(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	f, err := strconv.ParseFloat(s, 64)
+	if err == nil {
+		return f, nil
+	}
+	if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+		return 0, fmt.Errorf("%w: %q overflows float64", ErrOverflow, s)
+	}
+	return 0, fmt.Errorf("%w: %q is not a number", ErrParseFailed, s)
+}
+
+func to_string(v reflect.Value) (string, error) {
+	v = indirect_value(v)
+	if !v.IsValid() {
+		return "", ErrTypeAssertFailed
+	}
+
+	if v.CanInterface() {
+		if tm, ok := v.Interface().(encoding.TextMarshaler); ok && v.Kind() != reflect.String {
+			b, err := tm.MarshalText()
+			if err != nil {
+				return "", fmt.Errorf("%w: %v", ErrParseFailed, err)
+			}
+			return string(b), nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Slice:
+		if is_bytes(v) {
+			return string(v.Bytes()), nil
+		}
+	}
+	return "", ErrTypeAssertFailed
+}
+
+func to_bool(v reflect.Value) (bool, error) {
+	v = indirect_value(v)
+	if !v.IsValid() {
+		return false, ErrTypeAssertFailed
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() != 0, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() != 0, nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float() != 0, nil
+	case reflect.String:
+		return parse_bool(v.String())
+	case reflect.Slice:
+		if is_bytes(v) {
+			return parse_bool(string(v.Bytes()))
+		}
+	}
+	return false, ErrTypeAssertFailed
+}
+
+func parse_bool(s string) (bool, error) {
+	s = strings.TrimSpace(s)
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("%w: %q is not a boolean", ErrParseFailed, s)
+	}
+	return b, nil
+}
+
+var interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+func is_nillable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return true
+	}
+	return false
+}
+
+func is_int_kind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func is_uint_kind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func is_float_kind(k reflect.Kind) bool {
+	return k == reflect.Float32 || k == reflect.Float64
+}
+
+func is_number_kind(k reflect.Kind) bool {
+	return is_int_kind(k) || is_uint_kind(k) || is_float_kind(k)
+}
+
+// 将v转换为类型t，只在兼容的Kind之间转换：各宽度数值之间、具名类型与底层类型之间、string与[]byte之间
+// 转换会检查溢出及精度丢失，不兼容时返回ErrTypeMismatch
+func convert_value(v reflect.Value, t reflect.Type) (reflect.Value, error) {
+	if !v.IsValid() {
+		if is_nillable(t) {
+			return reflect.Zero(t), nil
+		}
+		return reflect.Value{}, fmt.Errorf("%w: cannot use nil as %s", ErrTypeMismatch, t)
+	}
+
+	if v.Type().AssignableTo(t) {
+		return v, nil
+	}
+
+	// interface取出实际值再判断
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return convert_value(reflect.Value{}, t)
+		}
+		return convert_value(v.Elem(), t)
+	}
+
+	sk := v.Kind()
+	nv := reflect.New(t).Elem()
+	switch tk := t.Kind(); {
+	case is_int_kind(tk) && is_number_kind(sk):
+		n, err := to_int64(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if nv.OverflowInt(n) {
+			return reflect.Value{}, fmt.Errorf("%w: %d overflows %s", ErrOverflow, n, t)
+		}
+		nv.SetInt(n)
+		return nv, nil
+	case is_uint_kind(tk) && is_number_kind(sk):
+		n, err := to_uint64(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if nv.OverflowUint(n) {
+			return reflect.Value{}, fmt.Errorf("%w: %d overflows %s", ErrOverflow, n, t)
+		}
+		nv.SetUint(n)
+		return nv, nil
+	case is_float_kind(tk) && is_number_kind(sk):
+		f, err := to_float64(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if nv.OverflowFloat(f) {
+			return reflect.Value{}, fmt.Errorf("%w: %g overflows %s", ErrOverflow, f, t)
+		}
+		nv.SetFloat(f)
+		return nv, nil
+	case (tk == reflect.Complex64 || tk == reflect.Complex128) && (sk == reflect.Complex64 || sk == reflect.Complex128):
+		c := v.Complex()
+		if nv.OverflowComplex(c) {
+			return reflect.Value{}, fmt.Errorf("%w: %v overflows %s", ErrOverflow, c, t)
+		}
+		nv.SetComplex(c)
+		return nv, nil
+	case tk == reflect.String && sk == reflect.String:
+		nv.SetString(v.String())
+		return nv, nil
+	case tk == reflect.String && is_bytes(v):
+		nv.SetString(string(v.Bytes()))
+		return nv, nil
+	case tk == reflect.Slice && t.Elem().Kind() == reflect.Uint8 && sk == reflect.String:
+		nv.SetBytes([]byte(v.String()))
+		return nv, nil
+	case tk == reflect.Slice && t.Elem().Kind() == reflect.Uint8 && is_bytes(v):
+		nv.SetBytes(append([]byte(nil), v.Bytes()...))
+		return nv, nil
+	case tk == sk && v.Type().ConvertibleTo(t):
+		// 底层类型相同的具名类型
+		return v.Convert(t), nil
+	}
+	return reflect.Value{}, fmt.Errorf("%w: cannot use %s as %s", ErrTypeMismatch, v.Type(), t)
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// 将字符串解析为类型t的值，用于默认值标签等场景
+// 支持基础类型、time.Duration、实现了encoding.TextUnmarshaler的类型（如time.Time），以及它们的指针
+// slice以","分隔元素，map以","分隔键值对、":"分隔键与值
+func parse_string_as(s string, t reflect.Type) (reflect.Value, error) {
+	if t.Kind() == reflect.Pointer {
+		ev, err := parse_string_as(s, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		pv := reflect.New(t.Elem())
+		pv.Elem().Set(ev)
+		return pv, nil
+	}
+
+	if t == durationType {
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%w: %v", ErrParseFailed, err)
+		}
+		return reflect.ValueOf(d), nil
+	}
+
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		pv := reflect.New(t)
+		if err := pv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return reflect.Value{}, fmt.Errorf("%w: %v", ErrParseFailed, err)
+		}
+		return pv.Elem(), nil
+	}
+
+	sv := reflect.ValueOf(s)
+	var (
+		v   interface{}
+		err error
+	)
+	switch k := t.Kind(); {
+	case k == reflect.String:
+		return sv.Convert(t), nil
+	case is_int_kind(k):
+		v, err = to_int64(sv)
+	case is_uint_kind(k):
+		v, err = to_uint64(sv)
+	case is_float_kind(k):
+		v, err = to_float64(sv)
+	case k == reflect.Bool:
+		v, err = to_bool(sv)
+	case k == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return convert_value(sv, t)
+	case k == reflect.Slice:
+		nv := reflect.MakeSlice(t, 0, 0)
+		if strings.TrimSpace(s) == "" {
+			return nv, nil
+		}
+		for _, item := range strings.Split(s, ",") {
+			ev, err := parse_string_as(strings.TrimSpace(item), t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			nv = reflect.Append(nv, ev)
+		}
+		return nv, nil
+	case k == reflect.Map:
+		nv := reflect.MakeMap(t)
+		if strings.TrimSpace(s) == "" {
+			return nv, nil
+		}
+		for _, item := range strings.Split(s, ",") {
+			kv := strings.SplitN(item, ":", 2)
+			if len(kv) != 2 {
+				return reflect.Value{}, fmt.Errorf("%w: %q is not a key:value pair", ErrParseFailed, item)
+			}
+			mk, err := parse_string_as(strings.TrimSpace(kv[0]), t.Key())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			mv, err := parse_string_as(strings.TrimSpace(kv[1]), t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			nv.SetMapIndex(mk, mv)
+		}
+		return nv, nil
+	default:
+		return reflect.Value{}, fmt.Errorf("%w: cannot parse %q as %s", ErrTypeMismatch, s, t)
+	}
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return convert_value(reflect.ValueOf(v), t)
+}
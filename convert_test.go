@@ -0,0 +1,130 @@
+package reflect_walker
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func Test_As_conversion(t *testing.T) {
+	type Port int
+	type Name string
+
+	testCases := []struct {
+		name   string
+		input  interface{}
+		conv   func(tv TreeVariable) (interface{}, error)
+		expect interface{}
+		err    error
+	}{
+		{
+			name:   "float64转int64",
+			input:  float64(8080),
+			conv:   func(tv TreeVariable) (interface{}, error) { return tv.AsInt64() },
+			expect: int64(8080),
+		},
+		{
+			name:  "float64转int64-精度丢失",
+			input: 3.14,
+			conv:  func(tv TreeVariable) (interface{}, error) { return tv.AsInt64() },
+			err:   ErrPrecisionLoss,
+		},
+		{
+			name:   "具名类型转int64",
+			input:  Port(443),
+			conv:   func(tv TreeVariable) (interface{}, error) { return tv.AsInt64() },
+			expect: int64(443),
+		},
+		{
+			name:   "int32转int64",
+			input:  int32(-7),
+			conv:   func(tv TreeVariable) (interface{}, error) { return tv.AsInt64() },
+			expect: int64(-7),
+		},
+		{
+			name:  "uint64转int64-溢出",
+			input: uint64(math.MaxUint64),
+			conv:  func(tv TreeVariable) (interface{}, error) { return tv.AsInt64() },
+			err:   ErrOverflow,
+		},
+		{
+			name:   "字符串转int64",
+			input:  " 42 ",
+			conv:   func(tv TreeVariable) (interface{}, error) { return tv.AsInt64() },
+			expect: int64(42),
+		},
+		{
+			name:  "字符串转int64-解析失败",
+			input: "abc",
+			conv:  func(tv TreeVariable) (interface{}, error) { return tv.AsInt64() },
+			err:   ErrParseFailed,
+		},
+		{
+			name:  "负数转uint64-溢出",
+			input: -1,
+			conv:  func(tv TreeVariable) (interface{}, error) { return tv.AsUint64() },
+			err:   ErrOverflow,
+		},
+		{
+			name:   "int转float64",
+			input:  7,
+			conv:   func(tv TreeVariable) (interface{}, error) { return tv.AsFloat64() },
+			expect: float64(7),
+		},
+		{
+			name:  "大整数转float64-精度丢失",
+			input: int64(1<<53 + 1),
+			conv:  func(tv TreeVariable) (interface{}, error) { return tv.AsFloat64() },
+			err:   ErrPrecisionLoss,
+		},
+		{
+			name:   "具名字符串转string",
+			input:  Name("alice"),
+			conv:   func(tv TreeVariable) (interface{}, error) { return tv.AsString() },
+			expect: "alice",
+		},
+		{
+			name:   "int转string",
+			input:  Port(80),
+			conv:   func(tv TreeVariable) (interface{}, error) { return tv.AsString() },
+			expect: "80",
+		},
+		{
+			name:   "字符串转bool",
+			input:  "true",
+			conv:   func(tv TreeVariable) (interface{}, error) { return tv.AsBool() },
+			expect: true,
+		},
+		{
+			name:  "nil转bool",
+			input: nil,
+			conv:  func(tv TreeVariable) (interface{}, error) { return tv.AsBool() },
+			err:   ErrTypeAssertFailed,
+		},
+	}
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			var (
+				got interface{}
+				err error
+			)
+			tw := NewTreeWalker(
+				WithRoutine(func(ctx context.Context, node TreeNode) {
+					got, err = v.conv(node.Value())
+				}),
+			)
+			tw.Walk(context.Background(), []interface{}{v.input})
+
+			if v.err != nil {
+				if !errors.Is(err, v.err) {
+					t.Errorf("error miss match: \n\tinput:  %+v\n\texpect:%v\n\tgot:   %v", v.input, v.err, err)
+				}
+				return
+			}
+			if err != nil || got != v.expect {
+				t.Errorf("miss match: \n\tinput:  %+v\n\texpect:%+v\n\tgot:   %+v (%v)", v.input, v.expect, got, err)
+			}
+		})
+	}
+}
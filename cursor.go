@@ -0,0 +1,99 @@
+package reflect_walker
+
+import "context"
+
+// 节点游标，按遍历顺序逐个取出节点，适合不便写成routine的场景
+//
+//	c := Nodes(ctx, in)
+//	for c.Next() {
+//		fmt.Println(c.Path(), c.Node().Value().Interface())
+//	}
+//	out, err := c.Close()
+//
+// 取出的节点与routine收到的一样，可以Set、Delete、Insert，修改在Close返回的结果中生效
+// 节点只在下一次调用Next或Close之前有效
+type NodeCursor struct {
+	ctx      context.Context
+	tr       *walker
+	state    *walkState
+	e        *engine
+	node     *treeNode // 当前节点
+	override bool      // 当前节点已被routine修改
+	done     bool
+}
+
+// 创建遍历in的游标，选项与NewTreeWalker相同
+// 设置了WithRoutine时，routine先于游标处理节点，被routine删除的节点不再交给游标
+// 游标总是在当前goroutine中按顺序遍历，WithParallelism不生效
+func Nodes(ctx context.Context, in interface{}, wo ...WalkOption) *NodeCursor {
+	tw := NewTreeWalker(wo...).(*walker)
+	tw.pulled = true
+	tw.parallelism = 0
+
+	ctx, state := tw.begin(ctx)
+	return &NodeCursor{ctx: ctx, tr: tw, state: state, e: tw.new_engine(ctx, root_scope(), in)}
+}
+
+// 前进到下一个节点，遍历结束时返回false
+func (c *NodeCursor) Next() bool {
+	if c.done {
+		return false
+	}
+	c.settle()
+
+	for {
+		node := c.e.next()
+		if node == nil {
+			c.done = true
+			return false
+		}
+
+		rt, override := c.tr.run_routines(c.ctx, node)
+		if rt == routine_delete && node.Type() != NodeType_literal {
+			c.e.resume(rt, override)
+			continue
+		}
+		c.node, c.override = node, override
+		return true
+	}
+}
+
+// 当前节点，Next返回false后为nil
+func (c *NodeCursor) Node() TreeNode {
+	if c.node == nil {
+		return nil
+	}
+	return c.node
+}
+
+// 当前节点的路径
+func (c *NodeCursor) Path() Path {
+	if c.node == nil {
+		return nil
+	}
+	return c.node.Path()
+}
+
+// 结束遍历，返回遍历结果与遍历中的第一个错误，与WalkWithError相同
+// 提前结束时剩余的节点不再交给游标，但仍会经过routine
+func (c *NodeCursor) Close() (interface{}, error) {
+	if !c.done {
+		c.settle()
+		for node := c.e.next(); node != nil; node = c.e.next() {
+			c.e.resume(c.tr.run_routines(c.ctx, node))
+		}
+		c.done = true
+	}
+	return c.e.out, c.state.err
+}
+
+// 应用对当前节点的修改
+func (c *NodeCursor) settle() {
+	if c.node == nil {
+		return
+	}
+	rt := c.node.getAction()
+	override := c.override || rt == routine_override
+	c.node = nil
+	c.e.resume(rt, override)
+}
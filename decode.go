@@ -0,0 +1,438 @@
+package reflect_walker
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrInvalidTarget = errors.New("decode target must be a non-nil pointer")
+	ErrUnusedKey     = errors.New("unused key")
+)
+
+// 带路径的错误
+type PathError struct {
+	Path Path
+	Err  error
+}
+
+func (pe *PathError) Error() string {
+	return fmt.Sprintf("%s: %v", pe.Path, pe.Err)
+}
+
+func (pe *PathError) Unwrap() error {
+	return pe.Err
+}
+
+// Decode的错误列表
+type DecodeErrors []*PathError
+
+func (de DecodeErrors) Error() string {
+	msgs := make([]string, len(de))
+	for i, e := range de {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type DecodeOption func(d *decoder)
+
+// 匹配字段时使用的标签，默认为json
+func WithDecodeTagName(name string) DecodeOption {
+	return func(d *decoder) {
+		d.tagName = name
+	}
+}
+
+// 将src中未使用的key作为错误返回
+func WithErrorUnused() DecodeOption {
+	return func(d *decoder) {
+		d.errorUnused = true
+	}
+}
+
+// 收集src中未使用的key
+func WithUnusedKeys(keys *[]Path) DecodeOption {
+	return func(d *decoder) {
+		d.unusedKeys = keys
+	}
+}
+
+type decoder struct {
+	tagName     string
+	errorUnused bool
+	unusedKeys  *[]Path
+	errs        DecodeErrors
+}
+
+// 将map[string]interface{}等通用结构解码到dst指向的类型化结构中
+// key按标签名、字段名、忽略大小写的字段名依次匹配字段；数值按需转换宽度并检查溢出；
+// 字符串可解析为数值、bool、time.Duration、time.Time等实现了encoding.TextUnmarshaler的类型；
+// 嵌套的指针、slice、map按需创建；转换错误按路径返回DecodeErrors，与未使用的key一样按路径排序
+func Decode(src interface{}, dst interface{}, opts ...DecodeOption) error {
+	dv := reflect.ValueOf(dst)
+	if dv.Kind() != reflect.Pointer || dv.IsNil() {
+		return ErrInvalidTarget
+	}
+
+	d := &decoder{tagName: "json"}
+	for _, option := range opts {
+		option(d)
+	}
+
+	d.decode(nil, reflect.ValueOf(src), dv.Elem())
+	// map按随机顺序遍历，排序后结果才是确定的
+	sort.SliceStable(d.errs, func(i, j int) bool { return path_less(d.errs[i].Path, d.errs[j].Path) })
+	if d.unusedKeys != nil {
+		keys := *d.unusedKeys
+		sort.SliceStable(keys, func(i, j int) bool { return path_less(keys[i], keys[j]) })
+	}
+	if len(d.errs) > 0 {
+		return d.errs
+	}
+	return nil
+}
+
+func (d *decoder) fail(path Path, err error) {
+	d.errs = append(d.errs, &PathError{Path: path, Err: err})
+}
+
+func (d *decoder) unused(path Path) {
+	if d.unusedKeys != nil {
+		*d.unusedKeys = append(*d.unusedKeys, path)
+	}
+	if d.errorUnused {
+		d.fail(path, ErrUnusedKey)
+	}
+}
+
+func child_path(path Path, elem interface{}) Path {
+	p := make(Path, len(path)+1)
+	copy(p, path)
+	p[len(path)] = elem
+	return p
+}
+
+func (d *decoder) decode(path Path, src reflect.Value, dst reflect.Value) {
+	for src.IsValid() && src.Kind() == reflect.Interface {
+		src = src.Elem()
+	}
+	if !src.IsValid() {
+		// nil不修改目标
+		return
+	}
+	if src.Kind() == reflect.Pointer {
+		if src.IsNil() {
+			return
+		}
+		if !src.Type().AssignableTo(dst.Type()) {
+			src = src.Elem()
+		}
+	}
+
+	if src.Type().AssignableTo(dst.Type()) && dst.Kind() != reflect.Map &&
+		dst.Kind() != reflect.Slice && dst.Kind() != reflect.Struct {
+		dst.Set(src)
+		return
+	}
+
+	switch dst.Kind() {
+	case reflect.Pointer:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		d.decode(path, src, dst.Elem())
+	case reflect.Interface:
+		if src.Type().AssignableTo(dst.Type()) {
+			dst.Set(src)
+		} else {
+			d.fail(path, fmt.Errorf("%w: cannot use %s as %s", ErrTypeMismatch, src.Type(), dst.Type()))
+		}
+	case reflect.Struct:
+		d.decode_struct(path, src, dst)
+	case reflect.Map:
+		d.decode_map(path, src, dst)
+	case reflect.Slice, reflect.Array:
+		d.decode_slice(path, src, dst)
+	default:
+		d.decode_literal(path, src, dst)
+	}
+}
+
+func (d *decoder) decode_literal(path Path, src reflect.Value, dst reflect.Value) {
+	var (
+		nv  reflect.Value
+		err error
+	)
+	switch {
+	case src.Kind() == reflect.String && dst.Kind() != reflect.String:
+		nv, err = parse_string_as(src.String(), dst.Type())
+	case dst.Kind() == reflect.String && src.Kind() != reflect.String && !is_bytes(src):
+		if s, serr := to_string(src); serr == nil {
+			nv = reflect.ValueOf(s).Convert(dst.Type())
+		} else {
+			err = fmt.Errorf("%w: cannot use %s as %s", ErrTypeMismatch, src.Type(), dst.Type())
+		}
+	default:
+		nv, err = convert_value(src, dst.Type())
+	}
+	if err != nil {
+		d.fail(path, err)
+		return
+	}
+	dst.Set(nv)
+}
+
+func (d *decoder) decode_struct(path Path, src reflect.Value, dst reflect.Value) {
+	if src.Kind() == reflect.String {
+		// time.Time等可由文本解析的结构体
+		d.decode_literal(path, src, dst)
+		return
+	}
+
+	if src.Kind() == reflect.Struct {
+		if src.Type().ConvertibleTo(dst.Type()) {
+			dst.Set(src.Convert(dst.Type()))
+			return
+		}
+		d.fail(path, fmt.Errorf("%w: cannot use %s as %s", ErrTypeMismatch, src.Type(), dst.Type()))
+		return
+	}
+
+	if src.Kind() != reflect.Map {
+		d.fail(path, fmt.Errorf("%w: cannot use %s as %s", ErrTypeMismatch, src.Type(), dst.Type()))
+		return
+	}
+
+	fields := struct_fields(dst.Type(), d.tagName)
+	iter := src.MapRange()
+	for iter.Next() {
+		key, err := to_string(iter.Key())
+		if err != nil {
+			d.fail(path, fmt.Errorf("%w: map key %v is not a string", ErrTypeMismatch, iter.Key()))
+			continue
+		}
+
+		kpath := child_path(path, key)
+		field, ok := fields.lookup(key)
+		if !ok {
+			d.unused(kpath)
+			continue
+		}
+		fv, err := field_by_index_alloc(dst, field.index)
+		if err != nil {
+			d.fail(kpath, err)
+			continue
+		}
+		d.decode(kpath, iter.Value(), fv)
+	}
+}
+
+func (d *decoder) decode_map(path Path, src reflect.Value, dst reflect.Value) {
+	dt := dst.Type()
+	switch src.Kind() {
+	case reflect.Map:
+	case reflect.Slice, reflect.Array:
+		// 以下标作为key，如Unflatten将key为0到n-1的一层重建为slice
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(dt, src.Len()))
+		}
+		for i := 0; i < src.Len(); i++ {
+			d.decode_map_entry(child_path(path, i), reflect.ValueOf(i), src.Index(i), dst)
+		}
+		return
+	case reflect.String:
+		d.decode_literal(path, src, dst)
+		return
+	default:
+		d.fail(path, fmt.Errorf("%w: cannot use %s as %s", ErrTypeMismatch, src.Type(), dt))
+		return
+	}
+
+	if dst.IsNil() {
+		dst.Set(reflect.MakeMapWithSize(dt, src.Len()))
+	}
+
+	iter := src.MapRange()
+	for iter.Next() {
+		d.decode_map_entry(child_path(path, iter.Key().Interface()), iter.Key(), iter.Value(), dst)
+	}
+}
+
+func (d *decoder) decode_map_entry(kpath Path, key, val reflect.Value, dst reflect.Value) {
+	dt := dst.Type()
+	nk := reflect.New(dt.Key()).Elem()
+	d.decode(kpath, key, nk)
+
+	nv := reflect.New(dt.Elem()).Elem()
+	if old := dst.MapIndex(nk); old.IsValid() {
+		// 在已有值上叠加
+		nv.Set(old)
+	}
+	d.decode(kpath, val, nv)
+	dst.SetMapIndex(nk, nv)
+}
+
+func (d *decoder) decode_slice(path Path, src reflect.Value, dst reflect.Value) {
+	dt := dst.Type()
+	if src.Kind() != reflect.Slice && src.Kind() != reflect.Array {
+		// 字符串按","分隔，其他单个值作为唯一元素
+		if src.Kind() == reflect.String {
+			d.decode_literal(path, src, dst)
+			return
+		}
+		if dst.Kind() == reflect.Slice {
+			nv := reflect.New(dt.Elem()).Elem()
+			d.decode(child_path(path, 0), src, nv)
+			dst.Set(reflect.Append(reflect.MakeSlice(dt, 0, 1), nv))
+			return
+		}
+		d.fail(path, fmt.Errorf("%w: cannot use %s as %s", ErrTypeMismatch, src.Type(), dt))
+		return
+	}
+
+	if dst.Kind() == reflect.Slice && is_bytes(dst) && is_bytes(src) {
+		dst.SetBytes(append([]byte(nil), src.Bytes()...))
+		return
+	}
+
+	n := src.Len()
+	if dst.Kind() == reflect.Array {
+		if n > dst.Len() {
+			d.fail(path, fmt.Errorf("%w: %d elements do not fit in %s", ErrOverflow, n, dt))
+			n = dst.Len()
+		}
+	} else {
+		dst.Set(reflect.MakeSlice(dt, n, n))
+	}
+
+	for i := 0; i < n; i++ {
+		d.decode(child_path(path, i), src.Index(i), dst.Index(i))
+	}
+}
+
+type fieldInfo struct {
+	name   string // 标签名或字段名
+	index  []int
+	tagged bool // 名字来自标签
+}
+
+type fieldSet []fieldInfo
+
+// 先精确匹配，再忽略大小写匹配
+func (fs fieldSet) lookup(key string) (fieldInfo, bool) {
+	for _, f := range fs {
+		if f.name == key {
+			return f, true
+		}
+	}
+	for _, f := range fs {
+		if strings.EqualFold(f.name, key) {
+			return f, true
+		}
+	}
+	return fieldInfo{}, false
+}
+
+// 列出可匹配的字段，匿名嵌入且没有指定标签名的结构体字段会被展开
+// 同名字段按encoding/json的规则取舍：嵌入层级浅的优先，同一层级有标签的优先，仍有多个时都忽略
+func struct_fields(t reflect.Type, tagName string) fieldSet {
+	all := collect_fields(t, tagName, nil, map[reflect.Type]bool{})
+
+	// 每个名字取胜的字段
+	winners := make(map[string]int, len(all))
+	ambiguous := map[string]bool{}
+	for i, f := range all {
+		w, ok := winners[f.name]
+		if !ok {
+			winners[f.name] = i
+			continue
+		}
+		switch cur := all[w]; {
+		case len(f.index) < len(cur.index), len(f.index) == len(cur.index) && f.tagged && !cur.tagged:
+			winners[f.name] = i
+			delete(ambiguous, f.name)
+		case len(f.index) == len(cur.index) && f.tagged == cur.tagged:
+			ambiguous[f.name] = true
+		}
+	}
+
+	fields := make(fieldSet, 0, len(winners))
+	for i, f := range all {
+		if winners[f.name] == i && !ambiguous[f.name] {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// 按字段顺序展开所有字段，visiting为当前路径上的嵌入类型，避免递归嵌入时无限展开
+func collect_fields(t reflect.Type, tagName string, prefix []int, visiting map[reflect.Type]bool) []fieldInfo {
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	var fields []fieldInfo
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, skip := parse_field_tag(sf, tagName)
+		if skip {
+			continue
+		}
+		index := append(append([]int(nil), prefix...), i)
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && ft.Kind() == reflect.Struct && name == sf.Name {
+			if !visiting[ft] {
+				fields = append(fields, collect_fields(ft, tagName, index, visiting)...)
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		tag, _ := sf.Tag.Lookup(tagName)
+		fields = append(fields, fieldInfo{name: name, index: index, tagged: strings.Split(tag, ",")[0] != ""})
+	}
+	return fields
+}
+
+// 解析字段标签，返回字段名（未指定时为字段名）、其余选项，以及是否忽略该字段
+func parse_field_tag(sf reflect.StructField, tagName string) (name string, opts []string, skip bool) {
+	tag, ok := sf.Tag.Lookup(tagName)
+	if !ok {
+		return sf.Name, nil, false
+	}
+	if tag == "-" {
+		return "", nil, true
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = sf.Name
+	}
+	return name, parts[1:], false
+}
+
+// 按下标取嵌套字段，途经的nil指针会被分配
+// 与encoding/json一样，nil的非公有嵌入指针无法分配，返回错误
+func field_by_index_alloc(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("%w: cannot set embedded pointer to unexported struct %s", ErrTypeMismatch, v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
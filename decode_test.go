@@ -0,0 +1,181 @@
+package reflect_walker
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_Decode(t *testing.T) {
+	type Port int
+	type replica struct {
+		Host string `json:"host"`
+		Port Port   `json:"port"`
+	}
+	type Base struct {
+		Version string
+	}
+	type config struct {
+		Base
+		Name     string            `json:"name"`
+		Timeout  time.Duration     `json:"timeout"`
+		Started  time.Time         `json:"started"`
+		Debug    bool              `json:"debug"`
+		Level    int8              `json:"level"`
+		Ratio    float32           `json:"ratio"`
+		Primary  *replica          `json:"primary"`
+		Replicas []replica         `json:"replicas"`
+		Labels   map[string]int    `json:"labels"`
+		Tags     []string          `json:"tags"`
+		Extra    interface{}       `json:"extra"`
+		Ignored  string            `json:"-"`
+		Raw      map[string]string `json:"raw"`
+	}
+	started := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	testCases := []struct {
+		name   string
+		input  interface{}
+		expect config
+		unused []string
+		errs   []PathError // 按路径排序
+	}{
+		{
+			name: "解码嵌套结构",
+			input: map[string]interface{}{
+				"version": "v1",
+				"name":    "svc",
+				"timeout": "1m30s",
+				"started": "2024-05-06T07:08:09Z",
+				"Debug":   "true",
+				"LEVEL":   float64(3),
+				"ratio":   0.5,
+				"primary": map[string]interface{}{"host": "db0", "port": float64(5432)},
+				"replicas": []interface{}{
+					map[string]interface{}{"host": "db1", "port": "5433"},
+				},
+				"labels":  map[string]interface{}{"a": float64(1)},
+				"tags":    "x,y",
+				"extra":   []interface{}{1, "a"},
+				"Ignored": "no",
+				"unknown": 1,
+			},
+			expect: config{
+				Base:     Base{Version: "v1"},
+				Name:     "svc",
+				Timeout:  90 * time.Second,
+				Started:  started,
+				Debug:    true,
+				Level:    3,
+				Ratio:    0.5,
+				Primary:  &replica{Host: "db0", Port: 5432},
+				Replicas: []replica{{Host: "db1", Port: 5433}},
+				Labels:   map[string]int{"a": 1},
+				Tags:     []string{"x", "y"},
+				Extra:    []interface{}{1, "a"},
+			},
+			unused: []string{"Ignored", "unknown"},
+		},
+		{
+			name: "转换错误带路径",
+			input: map[string]interface{}{
+				"level":    float64(300),
+				"timeout":  "soon",
+				"replicas": []interface{}{map[string]interface{}{"port": 1.5, "host": []interface{}{}}, map[string]interface{}{"port": "x"}},
+				"raw":      map[string]interface{}{"k": []interface{}{}},
+			},
+			errs: []PathError{
+				{Path: Path{"level"}, Err: ErrOverflow},
+				{Path: Path{"raw", "k"}, Err: ErrTypeMismatch},
+				{Path: Path{"replicas", 0, "host"}, Err: ErrTypeMismatch},
+				{Path: Path{"replicas", 0, "port"}, Err: ErrPrecisionLoss},
+				{Path: Path{"replicas", 1, "port"}, Err: ErrParseFailed},
+				{Path: Path{"timeout"}, Err: ErrParseFailed},
+			},
+		},
+	}
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			var (
+				got    config
+				unused []Path
+			)
+			err := Decode(v.input, &got, WithUnusedKeys(&unused))
+
+			var errs DecodeErrors
+			if err != nil && !errors.As(err, &errs) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(errs) != len(v.errs) {
+				t.Errorf("errors miss match: \n\texpect:%v\n\tgot:   %v", v.errs, err)
+			}
+			for i := 0; i < len(errs) && i < len(v.errs); i++ {
+				if e := errs[i]; !reflect.DeepEqual(e.Path, v.errs[i].Path) || !errors.Is(e, v.errs[i].Err) {
+					t.Errorf("error miss match at %d: \n\texpect:%s: %v\n\tgot:   %s: %v", i, v.errs[i].Path, v.errs[i].Err, e.Path, e.Err)
+				}
+			}
+			if v.errs != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(got, v.expect) {
+				t.Errorf("miss match: \n\tinput:  %+v\n\texpect:%+v\n\tgot:   %+v", v.input, v.expect, got)
+			}
+			var keys []string
+			for _, p := range unused {
+				keys = append(keys, p.String())
+			}
+			if !reflect.DeepEqual(keys, v.unused) {
+				t.Errorf("unused miss match: \n\texpect:%v\n\tgot:   %v", v.unused, keys)
+			}
+		})
+	}
+
+	if err := Decode(map[string]interface{}{}, config{}); err != ErrInvalidTarget {
+		t.Errorf("expect ErrInvalidTarget, got %v", err)
+	}
+	if err := Decode(map[string]interface{}{"x": 1}, &config{}, WithErrorUnused()); !errors.Is(err.(DecodeErrors)[0], ErrUnusedKey) {
+		t.Errorf("expect ErrUnusedKey, got %v", err)
+	}
+
+	// 同名字段：层级浅的优先，同一层级有标签的优先，仍有多个时都忽略
+	type Inner struct {
+		Name string
+		ID   int
+		Code string
+	}
+	type Other struct {
+		ID   int
+		Code string `json:"Code"`
+	}
+	type outer struct {
+		Inner
+		Other
+		Name string
+	}
+	var (
+		got    outer
+		unused []Path
+	)
+	err := Decode(map[string]interface{}{"Name": "outer", "ID": 1, "Code": "c"}, &got, WithUnusedKeys(&unused))
+	expect := outer{Name: "outer", Other: Other{Code: "c"}}
+	if err != nil || !reflect.DeepEqual(got, expect) || len(unused) != 1 || unused[0].String() != "ID" {
+		t.Errorf("miss match: \n\tinput: shadowed fields\n\texpect:%+v, [ID]\n\tgot:   %+v, %v, %v", expect, got, unused, err)
+	}
+
+	// nil的非公有嵌入指针无法分配，其余字段照常解码
+	type inner struct {
+		X int
+	}
+	type embedded struct {
+		*inner
+		Y int
+	}
+	var e embedded
+	err = Decode(map[string]interface{}{"X": 1, "Y": 2}, &e)
+	var errs DecodeErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Path.String() != "X" || !errors.Is(errs[0], ErrTypeMismatch) || e.Y != 2 || e.inner != nil {
+		t.Errorf("miss match: \n\tinput: unexported embedded pointer\n\texpect:X error, Y=2\n\tgot:   %+v, %v", e, err)
+	}
+}
@@ -0,0 +1,151 @@
+package reflect_walker
+
+import (
+	"fmt"
+	"reflect"
+)
+
+const DefaultTagName = "default"
+
+// 默认值提供者，owner为字段所属的结构体类型，ok为false表示该字段没有默认值
+// 返回值会按字段类型转换，字符串会按默认值标签的规则解析
+type DefaultsProvider func(owner reflect.Type, field reflect.StructField) (value interface{}, ok bool)
+
+// 为零值字段填充默认值，默认值来自`default:"..."`标签或注册的DefaultsProvider，提供者优先
+// 标量按字段类型解析，带默认值的nil指针、nil map会被分配；没有默认值的nil指针保持nil，表示缺失
+// 被填充的字段对应的节点Defaulted()返回true，默认值无法转换为字段类型时WalkWithError返回*PathError
+func WithDefaults(providers ...DefaultsProvider) WalkOption {
+	return func(tw *walker) {
+		tw.defaults = true
+		tw.defaultsProviders = append(tw.defaultsProviders, providers...)
+	}
+}
+
+// 与WithDefaults一起使用，nil的结构体指针字段在指向的类型内有默认值时也会被分配，自引用的类型只分配一层
+func WithDefaultPointers() WalkOption {
+	return func(tw *walker) {
+		tw.defaultPointers = true
+	}
+}
+
+// 获取字段的默认值，标签或提供者的值无法转换为字段类型时返回错误
+func (tr *walker) default_value(sc *scope, owner reflect.Type, field reflect.StructField) (reflect.Value, bool, error) {
+	for _, p := range tr.defaultsProviders {
+		dv, ok := p(owner, field)
+		if !ok {
+			continue
+		}
+		// 字符串按默认值标签的规则解析，解析失败时再按类型转换
+		var (
+			v   reflect.Value
+			err error
+		)
+		if s, isStr := dv.(string); isStr {
+			if v, err = parse_string_as(s, field.Type); err != nil {
+				if cv, cerr := convert_value(reflect.ValueOf(dv), field.Type); cerr == nil {
+					v, err = cv, nil
+				}
+			}
+		} else {
+			v, err = convert_value(reflect.ValueOf(dv), field.Type)
+		}
+		if err != nil {
+			return reflect.Value{}, false, fmt.Errorf("default value of field %s: %w", field.Name, err)
+		}
+		return v, true, nil
+	}
+
+	if tag, ok := field.Tag.Lookup(DefaultTagName); ok {
+		v, err := parse_string_as(tag, field.Type)
+		if err != nil {
+			return reflect.Value{}, false, fmt.Errorf("default tag %q of field %s: %w", tag, field.Name, err)
+		}
+		return v, true, nil
+	}
+
+	// 指向的结构体内有默认值时分配指针，自引用的类型只分配一层
+	if tr.defaultPointers && field.Type.Kind() == reflect.Pointer && field.Type.Elem().Kind() == reflect.Struct &&
+		!is_allocated(sc, field.Type.Elem()) && tr.has_defaults(field.Type.Elem(), nil) {
+		return reflect.New(field.Type.Elem()), true, nil
+	}
+	return reflect.Value{}, false, nil
+}
+
+// 当前路径上是否已经因默认值分配过该类型
+func is_allocated(sc *scope, t reflect.Type) bool {
+	for _, at := range sc.allocated {
+		if at == t {
+			return true
+		}
+	}
+	return false
+}
+
+func with_allocated(sc *scope, t reflect.Type) *scope {
+	ns := *sc
+	ns.allocated = make([]reflect.Type, len(sc.allocated), len(sc.allocated)+1)
+	copy(ns.allocated, sc.allocated)
+	ns.allocated = append(ns.allocated, t)
+	return &ns
+}
+
+// 判断结构体类型内（含嵌套结构体）是否有默认值字段
+func (tr *walker) has_defaults(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if cached, ok := tr.defaultsCache.Load(t); ok {
+		return cached.(bool)
+	}
+	if visiting[t] {
+		return false
+	}
+	// 只有最外层的结果是完整的，内层遇到环时被截断的false不能缓存
+	outermost := visiting == nil
+	if outermost {
+		visiting = map[reflect.Type]bool{}
+	}
+	visiting[t] = true
+
+	has := false
+	for i := 0; i < t.NumField() && !has; i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if _, ok := field.Tag.Lookup(DefaultTagName); ok {
+			has = true
+			break
+		}
+		for _, p := range tr.defaultsProviders {
+			if _, ok := p(t, field); ok {
+				has = true
+				break
+			}
+		}
+
+		ft := field.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && !tr.is_leaf_type(ft) {
+			has = has || tr.has_defaults(ft, visiting)
+		}
+	}
+
+	if has || outermost {
+		tr.defaultsCache.Store(t, has)
+	}
+	return has
+}
+
+// 子节点是否来自默认值
+func is_defaulted(sc *scope) bool {
+	return sc.defaulted
+}
+
+func with_defaulted(sc *scope, defaulted bool) *scope {
+	if sc.defaulted == defaulted {
+		return sc
+	}
+	ns := *sc
+	ns.defaulted = defaulted
+	return &ns
+}
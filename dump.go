@@ -0,0 +1,342 @@
+package reflect_walker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type DumpOption func(d *dumper)
+
+// 输出指针地址
+func WithDumpAddresses() DumpOption {
+	return func(d *dumper) {
+		d.addresses = true
+	}
+}
+
+// 输出在一行内，成员以", "分隔
+func WithDumpCompact() DumpOption {
+	return func(d *dumper) {
+		d.compact = true
+	}
+}
+
+// 每层的缩进，默认两个空格
+func WithDumpIndent(indent string) DumpOption {
+	return func(d *dumper) {
+		d.indent = indent
+	}
+}
+
+// 遍历选项，如WithLeafTypes、WithSortedMaps，使输出与routine看到的节点一致，其中的routine不会执行
+func WithDumpWalkOptions(wo ...WalkOption) DumpOption {
+	return func(d *dumper) {
+		d.wo = append(d.wo, wo...)
+	}
+}
+
+// 按遍历顺序输出v的树形结构，用于调试
+// 每个值带有Go类型名，slice带有长度与容量，map带有长度，指针形成的环以<cycle>标记，超过WithMaxDepth的值以<max depth>标记
+// 输出的成员与routine收到的节点一致：只包含公有字段，叶子类型与Walkable按遍历选项处理，map默认按key排序
+// 不会修改v
+//
+//	(*main.config) {
+//	  Name: (string) "svc"
+//	  Ports: ([]int) len=2 cap=2 {
+//	    0: (int) 80
+//	    1: (int) 443
+//	  }
+//	}
+func Dump(w io.Writer, v interface{}, opts ...DumpOption) error {
+	d := &dumper{indent: "  "}
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	tw := NewTreeWalker(append([]WalkOption{WithSortedMaps()}, d.wo...)...).(*walker)
+	tw.routines = []Node_routine{d.node}
+	tw.parallelism = 0
+	tw.copying = true
+	d.tr = tw
+	d.visiting = map[dumpRef]int{}
+
+	e := &engine{tr: tw, obs: d}
+	e.ctx, _ = tw.begin(context.Background())
+	e.start(root_scope(), v)
+	for node := e.next(); node != nil; node = e.next() {
+		e.resume(tw.run_routines(e.ctx, node))
+	}
+
+	d.buf.WriteByte('\n')
+	_, err := w.Write(d.buf.Bytes())
+	return err
+}
+
+// 与Dump相同，返回字符串
+func Sdump(v interface{}, opts ...DumpOption) string {
+	var sb strings.Builder
+	Dump(&sb, v, opts...)
+	return sb.String()
+}
+
+type dumper struct {
+	addresses bool
+	compact   bool
+	indent    string
+	wo        []WalkOption
+
+	tr       *walker
+	buf      bytes.Buffer
+	levels   []dumpLevel     // 正在输出的容器
+	visiting map[dumpRef]int // 当前路径上的指针，用于检测环
+}
+
+type dumpLevel struct {
+	keyed   bool // 成员以map或Walkable的key标识
+	members int
+	refs    []dumpRef
+}
+
+type dumpRef struct {
+	ptr uintptr
+	t   reflect.Type
+}
+
+func (d *dumper) enter(sc *scope, in interface{}) bool {
+	if len(d.levels) > 0 {
+		d.member(sc.elem)
+	}
+	if in == nil {
+		d.buf.WriteString("nil")
+		return false
+	}
+	return d.describe(reflect.ValueOf(in))
+}
+
+// 超过深度的值只输出类型与<max depth>，不再展开
+func (d *dumper) too_deep(sc *scope, in interface{}) {
+	if len(d.levels) > 0 {
+		d.member(sc.elem)
+	}
+	if is_nil(in) {
+		// nil没有成员，与未超过深度时的输出一致
+		if in == nil {
+			d.buf.WriteString("nil")
+		} else {
+			d.describe(reflect.ValueOf(in))
+		}
+		return
+	}
+	fmt.Fprintf(&d.buf, "(%s) <max depth>", reflect.TypeOf(in))
+}
+
+func (d *dumper) leave() {
+	level := d.levels[len(d.levels)-1]
+	d.levels = d.levels[:len(d.levels)-1]
+	d.untrack(level.refs)
+
+	if level.members > 0 && !d.compact {
+		d.newline()
+	}
+	d.buf.WriteByte('}')
+}
+
+// 输出routine收到的字面量节点，容器已在enter中输出
+func (d *dumper) node(ctx context.Context, node TreeNode) {
+	tn := node.(*treeNode)
+	tv := tn.nValue.(*treeVariable)
+	val := tv.rvalue()
+	if tn.nType == NodeType_literal || !d.tr.is_literal(&val) {
+		return
+	}
+
+	switch tn.nType {
+	case NodeType_slice_member:
+		d.member(tn.index)
+	case NodeType_map_pair:
+		if tn.mapKey.IsValid() {
+			d.member(tn.mapKey.Interface())
+		} else {
+			d.member(tn.elem)
+		}
+	default:
+		d.member(tn.elem)
+	}
+
+	if val.Kind() == reflect.Interface && val.IsNil() {
+		fmt.Fprintf(&d.buf, "(%s) nil", val.Type())
+		return
+	}
+	d.describe(val)
+}
+
+// 开始输出容器的一个成员
+func (d *dumper) member(label interface{}) {
+	level := &d.levels[len(d.levels)-1]
+	if d.compact {
+		if level.members > 0 {
+			d.buf.WriteString(", ")
+		}
+	} else {
+		d.newline()
+	}
+	level.members++
+
+	if s, ok := label.(string); ok && level.keyed {
+		d.buf.WriteString(strconv.Quote(s))
+	} else {
+		fmt.Fprint(&d.buf, label)
+	}
+	d.buf.WriteString(": ")
+}
+
+func (d *dumper) newline() {
+	d.buf.WriteByte('\n')
+	for i := 0; i < len(d.levels); i++ {
+		d.buf.WriteString(d.indent)
+	}
+}
+
+// 输出值的类型与内容，返回是否需要继续遍历其成员
+func (d *dumper) describe(v reflect.Value) bool {
+	var refs []dumpRef
+	if !d.describe_value(v, &refs) {
+		d.untrack(refs)
+		return false
+	}
+	d.levels = append(d.levels, dumpLevel{keyed: d.keyed(v), refs: refs})
+	return true
+}
+
+// 与open_frame一样解开指针与interface，refs记录解开的指针
+func (d *dumper) describe_value(v reflect.Value, refs *[]dumpRef) bool {
+	bare := false // 紧跟在指针之后，类型已输出
+	for {
+		t := v.Type()
+		plan := d.tr.plan(t)
+		if !bare {
+			fmt.Fprintf(&d.buf, "(%s)", t)
+		}
+
+		if plan.leaf || plan.literal && t.Kind() != reflect.Interface {
+			d.buf.WriteByte(' ')
+			d.buf.WriteString(dump_literal(v))
+			return false
+		}
+		if plan.walkable {
+			if is_nillable(t) && v.IsNil() {
+				d.buf.WriteString(" nil")
+				return false
+			}
+			d.buf.WriteString(" {")
+			return true
+		}
+
+		switch t.Kind() {
+		case reflect.Pointer:
+			if d.addresses {
+				fmt.Fprintf(&d.buf, "(%#x)", v.Pointer())
+			}
+			if v.IsNil() {
+				d.buf.WriteString(" nil")
+				return false
+			}
+			if !d.track(v, refs) {
+				return false
+			}
+			v, bare = v.Elem(), v.Elem().Kind() != reflect.Interface
+			if !bare {
+				d.buf.WriteByte(' ')
+			}
+		case reflect.Interface:
+			if v.IsNil() {
+				d.buf.WriteString(" nil")
+				return false
+			}
+			d.buf.WriteByte(' ')
+			v, bare = v.Elem(), false
+		case reflect.Map:
+			if v.IsNil() {
+				d.buf.WriteString(" nil")
+				return false
+			}
+			if !d.track(v, refs) {
+				return false
+			}
+			fmt.Fprintf(&d.buf, " len=%d {", v.Len())
+			return true
+		case reflect.Slice:
+			if v.IsNil() {
+				d.buf.WriteString(" nil")
+				return false
+			}
+			if v.Len() > 0 {
+				if !d.track(v, refs) {
+					return false
+				}
+			}
+			fmt.Fprintf(&d.buf, " len=%d cap=%d {", v.Len(), v.Cap())
+			return true
+		case reflect.Struct:
+			d.buf.WriteString(" {")
+			return true
+		default:
+			d.buf.WriteByte(' ')
+			d.buf.WriteString(dump_literal(v))
+			return false
+		}
+	}
+}
+
+// 记录当前路径上的指针，已在路径上时输出<cycle>并返回false
+func (d *dumper) track(v reflect.Value, refs *[]dumpRef) bool {
+	ref := dumpRef{v.Pointer(), v.Type()}
+	if d.visiting[ref] > 0 {
+		d.buf.WriteString(" <cycle>")
+		return false
+	}
+	d.visiting[ref]++
+	*refs = append(*refs, ref)
+	return true
+}
+
+func (d *dumper) untrack(refs []dumpRef) {
+	for _, ref := range refs {
+		if d.visiting[ref]--; d.visiting[ref] == 0 {
+			delete(d.visiting, ref)
+		}
+	}
+}
+
+// map与Walkable的成员以key标识
+func (d *dumper) keyed(v reflect.Value) bool {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if d.tr.plan(v.Type()).walkable {
+			return true
+		}
+		v = v.Elem()
+	}
+	return v.Kind() == reflect.Map || d.tr.plan(v.Type()).walkable
+}
+
+func dump_literal(v reflect.Value) string {
+	switch {
+	case v.Kind() == reflect.String:
+		return strconv.Quote(v.String())
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		if v.IsNil() {
+			return "nil"
+		}
+		return fmt.Sprintf("%q", v.Bytes())
+	case is_nillable(v.Type()) && v.IsNil():
+		return "nil"
+	case v.CanInterface():
+		return fmt.Sprint(v.Interface())
+	}
+	return fmt.Sprint(v)
+}
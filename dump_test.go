@@ -0,0 +1,117 @@
+package reflect_walker
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Dump(t *testing.T) {
+	type node struct {
+		Name    string
+		Next    *node
+		Tags    []string
+		Meta    map[string]interface{}
+		private int
+	}
+
+	cyclic := &node{Name: "a", Tags: make([]string, 1, 4), Meta: map[string]interface{}{"z": nil, "k": 1}}
+	cyclic.Next = cyclic
+	n := 5
+	when := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	testCases := []struct {
+		name   string
+		input  interface{}
+		opts   []DumpOption
+		expect string
+	}{
+		{
+			"环",
+			cyclic,
+			nil,
+			`(*reflect_walker.node) {
+  Name: (string) "a"
+  Next: (*reflect_walker.node) <cycle>
+  Tags: ([]string) len=1 cap=4 {
+    0: (string) ""
+  }
+  Meta: (map[string]interface {}) len=2 {
+    "k": (int) 1
+    "z": (interface {}) nil
+  }
+}
+`,
+		},
+		{
+			"一行",
+			cyclic,
+			[]DumpOption{WithDumpCompact()},
+			`(*reflect_walker.node) {Name: (string) "a", Next: (*reflect_walker.node) <cycle>, Tags: ([]string) len=1 cap=4 {0: (string) ""}, Meta: (map[string]interface {}) len=2 {"k": (int) 1, "z": (interface {}) nil}}
+`,
+		},
+		{
+			"共享的指针不是环",
+			[]interface{}{&n, &n, []byte("hi"), map[int]string{2: "b", 1: "a"}, []int{}},
+			[]DumpOption{WithDumpIndent("\t")},
+			`([]interface {}) len=5 cap=5 {
+	0: (*int) 5
+	1: (*int) 5
+	2: ([]uint8) "hi"
+	3: (map[int]string) len=2 {
+		1: (string) "a"
+		2: (string) "b"
+	}
+	4: ([]int) len=0 cap=0 {}
+}
+`,
+		},
+		{
+			"叶子类型",
+			map[string]interface{}{"when": when, "at": &when},
+			[]DumpOption{WithDumpWalkOptions(WithTextMarshalerLeaves())},
+			`(map[string]interface {}) len=2 {
+  "at": (*time.Time) 2024-01-02 03:04:05 +0000 UTC
+  "when": (time.Time) 2024-01-02 03:04:05 +0000 UTC
+}
+`,
+		},
+		{
+			"超过最大深度",
+			map[string]interface{}{"n": &node{Name: "x", Tags: []string{"t"}}},
+			[]DumpOption{WithDumpWalkOptions(WithMaxDepth(1))},
+			`(map[string]interface {}) len=1 {
+  "n": (*reflect_walker.node) {
+    Name: (string) "x"
+    Next: (*reflect_walker.node) nil
+    Tags: ([]string) <max depth>
+    Meta: (map[string]interface {}) nil
+  }
+}
+`,
+		},
+		{"字面量", "root", nil, "(string) \"root\"\n"},
+		{"nil", nil, nil, "nil\n"},
+	}
+
+	for _, tc := range testCases {
+		if got := Sdump(tc.input, tc.opts...); got != tc.expect {
+			t.Errorf("miss match: \n\tinput: %s\n\texpect:%s\n\tgot:   %s", tc.name, tc.expect, got)
+		}
+	}
+
+	// 指针地址
+	got := Sdump(&n, WithDumpAddresses())
+	if expect := fmt.Sprintf("(*int)(%p) 5\n", &n); got != expect {
+		t.Errorf("miss match: \n\tinput: addresses\n\texpect:%s\n\tgot:   %s", expect, got)
+	}
+
+	// 不修改输入
+	if cyclic.Next != cyclic || len(cyclic.Tags) != 1 || cap(cyclic.Tags) != 4 {
+		t.Errorf("input modified")
+	}
+	if strings.Contains(Sdump(cyclic), "private") {
+		t.Errorf("private field dumped")
+	}
+}
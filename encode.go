@@ -0,0 +1,283 @@
+package reflect_walker
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var ErrCycle = errors.New("cycle detected")
+
+// 叶子转换钩子，ok为true时以out作为该值的结果，不再展开
+type EncodeHook func(v interface{}) (out interface{}, ok bool)
+
+type EncodeOption func(e *encoder)
+
+// 字段名使用的标签，默认为json
+func WithEncodeTagName(name string) EncodeOption {
+	return func(e *encoder) {
+		e.tagName = name
+	}
+}
+
+// 注册叶子转换钩子，按注册顺序尝试
+func WithEncodeHook(hooks ...EncodeHook) EncodeOption {
+	return func(e *encoder) {
+		e.hooks = append(e.hooks, hooks...)
+	}
+}
+
+// 将time.Time按layout格式化为字符串
+func TimeFormatHook(layout string) EncodeHook {
+	return func(v interface{}) (interface{}, bool) {
+		t, ok := v.(time.Time)
+		if !ok {
+			return nil, false
+		}
+		return t.Format(layout), true
+	}
+}
+
+// 将实现了encoding.TextMarshaler的值转换为字符串
+func TextMarshalerHook(v interface{}) (interface{}, bool) {
+	tm, ok := v.(encoding.TextMarshaler)
+	if !ok {
+		return nil, false
+	}
+	b, err := tm.MarshalText()
+	if err != nil {
+		return nil, false
+	}
+	return string(b), true
+}
+
+type encoder struct {
+	tagName string
+	hooks   []EncodeHook
+}
+
+// 检测环时值的标识，同一底层数组上不同长度的slice不是同一个值
+type visitRef struct {
+	ptr uintptr
+	len int
+}
+
+// 指针、map、slice的标识，其他值及nil、空slice不会形成环
+func visit_ref(v reflect.Value) (visitRef, bool) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map:
+		if !v.IsNil() {
+			return visitRef{ptr: v.Pointer()}, true
+		}
+	case reflect.Slice:
+		if v.Len() > 0 {
+			return visitRef{ptr: v.Pointer(), len: v.Len()}, true
+		}
+	}
+	return visitRef{}, false
+}
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// 将struct或map转换为由map[string]interface{}、[]interface{}与字面量组成的通用结构
+// 字段名取自标签（默认json），支持"-"、omitempty，匿名嵌入或带inline选项的结构体字段会被展开到上一级
+// 实现了encoding.TextMarshaler或json.Marshaler的值默认原样保留，可通过钩子转换
+// 字段的取舍与WithStructAsMap相同，key冲突时返回ErrKeyCollision
+func ToMap(v interface{}, opts ...EncodeOption) (map[string]interface{}, error) {
+	out, err := ToGeneric(v, opts...)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := out.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: cannot convert %T to map", ErrTypeMismatch, v)
+	}
+	return m, nil
+}
+
+// 与ToMap相同，但接受任意值，slice转换为[]interface{}，字面量原样返回
+func ToGeneric(v interface{}, opts ...EncodeOption) (interface{}, error) {
+	e := &encoder{tagName: "json"}
+	for _, option := range opts {
+		option(e)
+	}
+
+	// 在遍历引擎上输出为通用结构：struct按字段计划转换为map，其余容器在进入前由intercept统一类型
+	tw := &walker{maxDepth: NoDepthLimit, copying: true, structAsMap: true, tagName: e.tagName, intercept: e.intercept}
+	ctx, state := tw.begin(context.Background())
+	eng := &engine{tr: tw, ctx: ctx, obs: &cycleGuard{tr: tw, ctx: ctx}}
+	eng.start(root_scope(), v)
+	// 没有routine，不会生成节点
+	eng.next()
+	if state.err != nil {
+		return nil, state.err
+	}
+	return eng.out, nil
+}
+
+// 遍历前转换每个值，返回true时作为结果不再遍历
+// nil、钩子与marshaler的结果、字面量直接返回；map的key转换为字符串，slice转换为[]interface{}后继续遍历
+func (e *encoder) intercept(in interface{}) (interface{}, bool) {
+	v := reflect.ValueOf(in)
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return nil, true
+		}
+	}
+
+	if v.CanInterface() {
+		for _, hook := range e.hooks {
+			if out, ok := hook(in); ok {
+				return out, true
+			}
+		}
+		// 值接收者实现的类型解开指针后再判断，如*time.Time；仅指针实现的保留指针，如*big.Int
+		if is_marshaler(v.Type()) && (v.Kind() != reflect.Pointer || !is_marshaler(v.Type().Elem())) {
+			return in, true
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Struct, reflect.Array:
+		return in, false
+	case reflect.Map:
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := to_string(iter.Key())
+			if err != nil {
+				key = fmt.Sprint(iter.Key().Interface())
+			}
+			m[key] = iter.Value().Interface()
+		}
+		return m, false
+	case reflect.Slice:
+		if is_bytes(v) {
+			return v.Bytes(), true
+		}
+		return array_members(in), false
+	}
+	return basic_value(v), true
+}
+
+// 检测环：记录当前路径上的指针、map、slice，再次进入时记录ErrCycle并不再遍历
+type cycleGuard struct {
+	tr       *walker
+	ctx      context.Context
+	refs     []visitRef
+	tracked  []bool
+	visiting map[visitRef]bool
+}
+
+func (g *cycleGuard) enter(sc *scope, in interface{}) bool {
+	ref, ok := visit_ref(reflect.ValueOf(in))
+	if ok {
+		if g.visiting[ref] {
+			g.tr.report(g.ctx, &PathError{Path: sc.path(0), Err: ErrCycle})
+			return false
+		}
+		if g.visiting == nil {
+			g.visiting = map[visitRef]bool{}
+		}
+		g.visiting[ref] = true
+	}
+	g.refs = append(g.refs, ref)
+	g.tracked = append(g.tracked, ok)
+	return true
+}
+
+func (g *cycleGuard) leave() {
+	n := len(g.refs) - 1
+	if g.tracked[n] {
+		delete(g.visiting, g.refs[n])
+	}
+	g.refs, g.tracked = g.refs[:n], g.tracked[:n]
+}
+
+func (g *cycleGuard) too_deep(sc *scope, in interface{}) {}
+
+func is_marshaler(t reflect.Type) bool {
+	return t.Implements(textMarshalerType) || t.Implements(jsonMarshalerType)
+}
+
+func has_option(opts []string, name string) bool {
+	for _, o := range opts {
+		if o == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 与encoding/json的omitempty判断一致
+func is_empty_value(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
+
+// 具名的基础类型转换为对应的内置类型，如`type Port int`转换为int
+func basic_value(v reflect.Value) interface{} {
+	var bt reflect.Type
+	switch v.Kind() {
+	case reflect.Bool:
+		bt = reflect.TypeOf(false)
+	case reflect.Int:
+		bt = reflect.TypeOf(int(0))
+	case reflect.Int8:
+		bt = reflect.TypeOf(int8(0))
+	case reflect.Int16:
+		bt = reflect.TypeOf(int16(0))
+	case reflect.Int32:
+		bt = reflect.TypeOf(int32(0))
+	case reflect.Int64:
+		bt = reflect.TypeOf(int64(0))
+	case reflect.Uint:
+		bt = reflect.TypeOf(uint(0))
+	case reflect.Uint8:
+		bt = reflect.TypeOf(uint8(0))
+	case reflect.Uint16:
+		bt = reflect.TypeOf(uint16(0))
+	case reflect.Uint32:
+		bt = reflect.TypeOf(uint32(0))
+	case reflect.Uint64:
+		bt = reflect.TypeOf(uint64(0))
+	case reflect.Float32:
+		bt = reflect.TypeOf(float32(0))
+	case reflect.Float64:
+		bt = reflect.TypeOf(float64(0))
+	case reflect.Complex64:
+		bt = reflect.TypeOf(complex64(0))
+	case reflect.Complex128:
+		bt = reflect.TypeOf(complex128(0))
+	case reflect.String:
+		bt = reflect.TypeOf("")
+	}
+	if bt != nil && v.Type() != bt {
+		return v.Convert(bt).Interface()
+	}
+	if !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
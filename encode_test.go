@@ -0,0 +1,144 @@
+package reflect_walker
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_ToMap(t *testing.T) {
+	type Port int
+	type Meta struct {
+		Owner string `json:"owner"`
+		Name  string `json:"name"`
+	}
+	type audit struct {
+		Created time.Time `json:"created"`
+	}
+	type service struct {
+		Meta
+		Name    string            `json:"name"`
+		Port    Port              `json:"port"`
+		Secret  string            `json:"-"`
+		Note    string            `json:"note,omitempty"`
+		Hosts   []string          `json:"hosts"`
+		Labels  map[string]string `json:"labels,omitempty"`
+		Audit   audit             `json:"audit,inline"`
+		Amount  *big.Int          `json:"amount"`
+		Backup  *service          `json:"backup"`
+		private int
+	}
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	testCases := []struct {
+		name    string
+		input   interface{}
+		options []EncodeOption
+		expect  map[string]interface{}
+	}{
+		{
+			name: "标签、omitempty与内嵌展开",
+			input: &service{
+				Meta:   Meta{Owner: "ops", Name: "shadowed"},
+				Name:   "api",
+				Port:   80,
+				Secret: "s",
+				Hosts:  []string{"a"},
+				Audit:  audit{Created: created},
+				Amount: big.NewInt(7),
+			},
+			expect: map[string]interface{}{
+				"owner":   "ops",
+				"name":    "api",
+				"port":    80,
+				"hosts":   []interface{}{"a"},
+				"created": created,
+				"amount":  big.NewInt(7),
+				"backup":  nil,
+			},
+		},
+		{
+			name:    "钩子转换时间",
+			input:   service{Name: "api", Audit: audit{Created: created}, Backup: &service{Name: "b"}},
+			options: []EncodeOption{WithEncodeHook(TimeFormatHook(time.RFC3339))},
+			expect: map[string]interface{}{
+				"owner":   "",
+				"name":    "api",
+				"port":    0,
+				"hosts":   nil,
+				"created": "2024-01-02T03:04:05Z",
+				"amount":  nil,
+				"backup": map[string]interface{}{
+					"owner":   "",
+					"name":    "b",
+					"port":    0,
+					"hosts":   nil,
+					"created": "0001-01-01T00:00:00Z",
+					"amount":  nil,
+					"backup":  nil,
+				},
+			},
+		},
+		{
+			name: "自定义标签",
+			input: struct {
+				A int `yaml:"a"`
+			}{A: 1},
+			options: []EncodeOption{WithEncodeTagName("yaml")},
+			expect:  map[string]interface{}{"a": 1},
+		},
+		{
+			name:   "map的key转换为字符串",
+			input:  map[int]interface{}{1: []int{2}},
+			expect: map[string]interface{}{"1": []interface{}{2}},
+		},
+	}
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			got, err := ToMap(v.input, v.options...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, v.expect) {
+				t.Errorf("miss match: \n\tinput:  %+v\n\texpect:%+v\n\tgot:   %+v", v.input, v.expect, got)
+			}
+		})
+	}
+
+	cyclic := &service{Name: "a"}
+	cyclic.Backup = cyclic
+	if _, err := ToMap(cyclic); !errors.Is(err, ErrCycle) {
+		t.Errorf("expect ErrCycle, got %v", err)
+	}
+	selfMap := map[string]interface{}{}
+	selfMap["self"] = selfMap
+	if _, err := ToMap(selfMap); !errors.Is(err, ErrCycle) {
+		t.Errorf("expect ErrCycle, got %v", err)
+	}
+	selfSlice := []interface{}{nil}
+	selfSlice[0] = selfSlice
+	if _, err := ToGeneric(selfSlice); !errors.Is(err, ErrCycle) {
+		t.Errorf("expect ErrCycle, got %v", err)
+	}
+	// 同一底层数组上更短的slice不是环
+	prefix := []interface{}{1, nil}
+	prefix[1] = prefix[:1]
+	if got, err := ToGeneric(prefix); err != nil || !reflect.DeepEqual(got, []interface{}{1, []interface{}{1}}) {
+		t.Errorf("miss match: \n\tinput:  %+v\n\texpect:%+v\n\tgot:   %+v, %v", prefix, []interface{}{1, []interface{}{1}}, got, err)
+	}
+	// 遍历时struct输出为map使用同一份字段计划，同样展开inline、省略omitempty的零值
+	in := service{Name: "api", Note: "n", Audit: audit{Created: created}}
+	walked := NewTreeWalker(WithStructAsMap(), WithLeafTypes(time.Time{})).Walk(context.Background(), in).(map[string]interface{})
+	for _, key := range []string{"note", "created", "labels"} {
+		_, ok := walked[key]
+		if expect := key != "labels"; ok != expect {
+			t.Errorf("miss match: \n\tinput:  %+v\n\texpect:%v\n\tgot:   %+v", in, key, walked)
+		}
+	}
+	if _, err := ToMap([]int{1}); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("expect ErrTypeMismatch, got %v", err)
+	}
+}
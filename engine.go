@@ -0,0 +1,404 @@
+package reflect_walker
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// 遍历引擎
+// 用显式的栈代替递归：每个正在遍历的容器对应栈上的一个frame，frame逐步推进，
+// 需要先遍历子值时交给引擎压栈，子值遍历完成后再把结果交回frame；
+// 需要执行routine时引擎暂停并交出节点，节点处理完后从暂停处继续。
+// 因此输入嵌套再深也不会耗尽goroutine的栈，遍历也可以在任意节点处暂停、恢复
+
+type stepKind int
+
+const (
+	step_done    stepKind = iota // frame已完成，结果由result返回
+	step_descend                 // 需要先遍历子值
+	step_node                    // 需要对节点执行routine
+)
+
+type step struct {
+	kind   stepKind
+	sc     *scope      // 子值所在的scope
+	in     interface{} // 待遍历的子值
+	direct bool        // 子值直接按Kind遍历，不检查深度、叶子类型与Walkable
+	node   *treeNode   // 待处理的节点
+}
+
+type frame interface {
+	// 推进一步
+	next() step
+	// 子值遍历完成
+	child_done(out interface{})
+	// 节点处理完成，rt为节点最终的动作，override表示key或值被修改过
+	node_done(rt routine_action, override bool)
+	// frame完成后的遍历结果
+	result() interface{}
+	// 出栈后放回复用池
+	release()
+}
+
+// 容器成员的处理阶段
+const (
+	member_begin   = iota // 尚未开始
+	member_walking        // 等待子值遍历完成
+	member_ready          // 子值已遍历，等待生成节点
+	member_node           // 等待节点处理完成
+)
+
+// frame在每个容器上都会创建，与节点一样复用
+var (
+	literalFramePool  = sync.Pool{New: func() interface{} { return new(literalFrame) }}
+	pointerFramePool  = sync.Pool{New: func() interface{} { return new(pointerFrame) }}
+	sliceFramePool    = sync.Pool{New: func() interface{} { return new(sliceFrame) }}
+	mapFramePool      = sync.Pool{New: func() interface{} { return new(mapFrame) }}
+	structFramePool   = sync.Pool{New: func() interface{} { return new(structFrame) }}
+	walkableFramePool = sync.Pool{New: func() interface{} { return new(walkableFrame) }}
+)
+
+// 遍历的观察者，用于Dump等需要知道容器边界的场景
+type observer interface {
+	// 开始遍历一个值，返回false时不再遍历，原样保留
+	enter(sc *scope, in interface{}) bool
+	// enter返回true的值遍历完成
+	leave()
+	// 超过WithMaxDepth不再遍历的值，不会调用enter
+	too_deep(sc *scope, in interface{})
+}
+
+type engine struct {
+	tr    *walker
+	ctx   context.Context
+	obs   observer
+	stack []frame
+	out   interface{} // 遍历结果，栈为空后有效
+}
+
+func (tr *walker) new_engine(ctx context.Context, sc *scope, in interface{}) *engine {
+	e := &engine{tr: tr, ctx: ctx}
+	e.start(sc, in)
+	return e
+}
+
+// 从in开始遍历
+func (e *engine) start(sc *scope, in interface{}) {
+	if out, pushed := e.open(sc, in, false); !pushed {
+		e.out = out
+	}
+}
+
+// 推进遍历，直到遇到需要执行routine的节点，返回nil表示遍历已结束
+func (e *engine) next() *treeNode {
+	for len(e.stack) > 0 {
+		top := e.stack[len(e.stack)-1]
+		st := top.next()
+		switch st.kind {
+		case step_node:
+			return st.node
+		case step_descend:
+			if out, pushed := e.open(st.sc, st.in, st.direct); !pushed {
+				top.child_done(out)
+			}
+		default:
+			e.stack[len(e.stack)-1] = nil
+			e.stack = e.stack[:len(e.stack)-1]
+			out := top.result()
+			top.release()
+			if e.obs != nil {
+				e.obs.leave()
+			}
+			if len(e.stack) == 0 {
+				e.out = out
+			} else {
+				e.stack[len(e.stack)-1].child_done(out)
+			}
+		}
+	}
+	return nil
+}
+
+// 当前节点处理完成
+func (e *engine) resume(rt routine_action, override bool) {
+	e.stack[len(e.stack)-1].node_done(rt, override)
+}
+
+// 开始遍历in，需要逐步遍历的值压栈，否则直接返回结果
+func (e *engine) open(sc *scope, in interface{}, direct bool) (interface{}, bool) {
+	if !direct {
+		var too_deep bool
+		if sc, too_deep = e.tr.dive(sc); too_deep {
+			if e.obs != nil {
+				e.obs.too_deep(sc, in)
+			}
+			if e.tr.tooDeep != nil && !is_nil(in) {
+				return e.tr.tooDeep, false
+			}
+			return in, false
+		}
+	}
+	if e.obs != nil && !e.obs.enter(sc, in) {
+		return in, false
+	}
+	f, out := e.tr.open_frame(e.ctx, sc, in, direct)
+	if f == nil {
+		if e.obs != nil {
+			e.obs.leave()
+		}
+		return out, false
+	}
+	e.stack = append(e.stack, f)
+	return nil, true
+}
+
+// 按类型选择遍历方式，不需要遍历的值原样返回
+func (tr *walker) open_frame(ctx context.Context, sc *scope, in interface{}, direct bool) (frame, interface{}) {
+	if in == nil {
+		return nil, in
+	}
+	if tr.intercept != nil {
+		out, done := tr.intercept(in)
+		if done || out == nil {
+			return nil, out
+		}
+		in = out
+	}
+	if tr.limits != nil {
+		// 遍历的起点或指针指向的字符串，容器成员在各容器中检查
+		nval, act := tr.limit_bytes(ctx, reflect.ValueOf(in), func() Path { return sc.path(0) })
+		switch act {
+		case limit_cut:
+			in = nval.Interface()
+		case limit_drop, limit_stop:
+			return nil, in
+		}
+	}
+	intyp := reflect.TypeOf(in)
+	plan := tr.plan(intyp)
+
+	if !direct {
+		if plan.leaf {
+			return tr.literal_frame(sc, in, !tr.copying && intyp.Kind() == reflect.Pointer && !reflect.ValueOf(in).IsNil())
+		}
+		if plan.walkable {
+			if intyp.Kind() == reflect.Pointer && reflect.ValueOf(in).IsNil() {
+				return nil, in
+			}
+			return tr.walkable_frame(ctx, sc, in)
+		}
+	}
+
+	switch plan.kind {
+	case reflect.Map:
+		return tr.map_frame(ctx, sc, in)
+	case reflect.Slice:
+		if intyp.Elem().Kind() == reflect.Uint8 {
+			// []byte作为整体处理
+			return tr.literal_frame(sc, in, false)
+		}
+		return tr.slice_frame(ctx, sc, in)
+	case reflect.Array:
+		if tr.copying {
+			// 不修改输入时数组按slice遍历，输出为[]interface{}
+			return tr.slice_frame(ctx, sc, array_members(in))
+		}
+	case reflect.Struct:
+		return tr.struct_frame(ctx, sc, in)
+	case reflect.Pointer:
+		return tr.pointer_frame(ctx, sc, in)
+	case reflect.Interface:
+		// reflect.TypeOf总是返回实际类型，interface在各容器中解开后处理
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, // 有符号数
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, // 无符号数
+		reflect.Float32, reflect.Float64, // 浮点数
+		reflect.Complex64, reflect.Complex128, // 复数
+		reflect.String, // 字符串
+		reflect.Bool:   // 布尔
+		return tr.literal_frame(sc, in, false)
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		// 无法展开的值，包括指针指向的及遍历的起点，这些位置没有节点
+		if tr.opaque != nil {
+			return nil, tr.opaque(in)
+		}
+	default:
+	}
+	return nil, in
+}
+
+func array_members(in interface{}) []interface{} {
+	av := reflect.ValueOf(in)
+	members := make([]interface{}, av.Len())
+	for i := range members {
+		members[i] = av.Index(i).Interface()
+	}
+	return members
+}
+
+// 字面量，settable时in为指向字面量的指针，修改直接写入指向的值
+type literalFrame struct {
+	in       interface{}
+	inval    reflect.Value
+	settable bool
+	node     *treeNode
+	emitted  bool
+}
+
+func (tr *walker) literal_frame(sc *scope, in interface{}, settable bool) (frame, interface{}) {
+	if !tr.emits_nodes() {
+		return nil, in
+	}
+
+	intyp := reflect.TypeOf(in)
+	inval := reflect.ValueOf(in)
+	if settable {
+		intyp = intyp.Elem()
+		inval = inval.Elem()
+	}
+
+	node := new_node(treeNode{
+		nType:     NodeType_literal,
+		defaulted: tr.defaults && is_defaulted(sc),
+		parent:    sc,
+	})
+	node.set_value(treeVariable{t: intyp, slot: intyp, loose: !settable && tr.is_loose(sc), rv: inval})
+	f := literalFramePool.Get().(*literalFrame)
+	*f = literalFrame{in: in, inval: inval, settable: settable, node: node}
+	return f, nil
+}
+
+func (f *literalFrame) next() step {
+	if !f.emitted {
+		f.emitted = true
+		return step{kind: step_node, node: f.node}
+	}
+	return step{}
+}
+
+func (f *literalFrame) child_done(out interface{}) {}
+
+func (f *literalFrame) node_done(rt routine_action, override bool) {
+	if override {
+		if f.settable {
+			f.inval.Set(f.node.nValue.rvalue())
+		} else {
+			f.in = f.node.nValue.Interface()
+		}
+	}
+	f.node.release()
+	f.node = nil
+}
+
+func (f *literalFrame) result() interface{} {
+	return f.in
+}
+
+func (f *literalFrame) release() {
+	*f = literalFrame{}
+	literalFramePool.Put(f)
+}
+
+// 指向map、slice、interface的指针，遍历指向的值后写回，类型发生变化（如jsonable）无法写回时返回新值
+type pointerFrame struct {
+	sc      *scope
+	in      interface{}
+	inval   reflect.Value
+	child   interface{}
+	direct  bool
+	started bool
+	out     interface{}
+}
+
+func (tr *walker) pointer_frame(ctx context.Context, sc *scope, in interface{}) (frame, interface{}) {
+	inval := reflect.ValueOf(in)
+	typ := inval.Type().Elem()
+	if inval.IsNil() {
+		return nil, in
+	}
+
+	var (
+		child  interface{}
+		direct bool
+	)
+	if tr.copying {
+		// 不修改输入，遍历指向的值并返回结果
+		elem := inval.Elem()
+		if typ.Kind() == reflect.Interface {
+			if elem.IsNil() {
+				return nil, in
+			}
+			elem = elem.Elem()
+		}
+		return tr.open_frame(ctx, sc, elem.Interface(), false)
+	}
+
+	switch typ.Kind() {
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return tr.literal_frame(sc, in, true)
+		}
+		child, direct = inval.Elem().Interface(), true
+	case reflect.Map:
+		child, direct = inval.Elem().Interface(), true
+	case reflect.Interface:
+		elem := inval.Elem()
+		if elem.IsNil() {
+			return nil, in
+		}
+		child = elem.Elem().Interface()
+	case reflect.Struct:
+		return tr.struct_frame(ctx, sc, in)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, // 有符号数
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, // 无符号数
+		reflect.Float32, reflect.Float64, // 浮点数
+		reflect.Complex64, reflect.Complex128, // 复数
+		reflect.String, // 字符串
+		reflect.Bool:   // 布尔
+		return tr.literal_frame(sc, in, true)
+	default:
+		return nil, in
+	}
+
+	f := pointerFramePool.Get().(*pointerFrame)
+	*f = pointerFrame{sc: sc, in: in, inval: inval, child: child, direct: direct, out: in}
+	return f, nil
+}
+
+// 有类型信息的nil同样视为nil
+func is_nil(in interface{}) bool {
+	v := reflect.ValueOf(in)
+	return !v.IsValid() || is_nillable(v.Type()) && v.IsNil()
+}
+
+func (f *pointerFrame) next() step {
+	if !f.started {
+		f.started = true
+		return step{kind: step_descend, sc: f.sc, in: f.child, direct: f.direct}
+	}
+	return step{}
+}
+
+func (f *pointerFrame) child_done(out interface{}) {
+	nval := reflect.ValueOf(out)
+	typ := f.inval.Type().Elem()
+	if !nval.IsValid() {
+		nval = reflect.Zero(typ)
+	}
+	if !nval.Type().AssignableTo(typ) {
+		f.out = out
+		return
+	}
+	f.inval.Elem().Set(nval)
+}
+
+func (f *pointerFrame) node_done(rt routine_action, override bool) {}
+
+func (f *pointerFrame) result() interface{} {
+	return f.out
+}
+
+func (f *pointerFrame) release() {
+	*f = pointerFrame{}
+	pointerFramePool.Put(f)
+}
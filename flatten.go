@@ -0,0 +1,234 @@
+package reflect_walker
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type FlattenOption func(f *flattener)
+
+// 各级之间的分隔符，默认为"."
+func WithFlattenSeparator(sep string) FlattenOption {
+	return func(f *flattener) {
+		f.sep = sep
+	}
+}
+
+// key使用JSON Pointer（RFC 6901）格式，如/db/replicas/0/host，key中的"~"与"/"转义为"~0"与"~1"
+func WithJSONPointerKeys() FlattenOption {
+	return func(f *flattener) {
+		f.pointer = true
+	}
+}
+
+// Flatten将值转换为通用结构时使用的选项，如WithEncodeTagName
+func WithFlattenEncodeOptions(eo ...EncodeOption) FlattenOption {
+	return func(f *flattener) {
+		f.eo = append(f.eo, eo...)
+	}
+}
+
+// Unflatten解码到目标时使用的选项，如WithErrorUnused
+func WithFlattenDecodeOptions(do ...DecodeOption) FlattenOption {
+	return func(f *flattener) {
+		f.do = append(f.do, do...)
+	}
+}
+
+type flattener struct {
+	sep     string
+	pointer bool
+	eo      []EncodeOption
+	do      []DecodeOption
+}
+
+func new_flattener(opts []FlattenOption) *flattener {
+	f := &flattener{sep: "."}
+	for _, option := range opts {
+		option(f)
+	}
+	return f
+}
+
+// 将嵌套的值展开为路径到叶子的映射，如{"db.replicas.0.host": "a"}，用于环境变量、标签、键值存储等扁平的场景
+// 先按ToGeneric转换，字段名同样取自标签；空的map与slice作为叶子保留，根是字面量时key为""
+// key中含有分隔符时无法区分层级，不同路径展开后相同时返回ErrKeyCollision
+func Flatten(v interface{}, opts ...FlattenOption) (map[string]interface{}, error) {
+	f := new_flattener(opts)
+	generic, err := ToGeneric(v, f.eo...)
+	if err != nil {
+		return nil, err
+	}
+
+	out := map[string]interface{}{}
+	if err := f.flatten(nil, generic, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (f *flattener) flatten(path Path, v interface{}, out map[string]interface{}) error {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if len(val) > 0 {
+			for k, elem := range val {
+				if err := f.flatten(child_path(path, k), elem, out); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case []interface{}:
+		if len(val) > 0 {
+			for i, elem := range val {
+				if err := f.flatten(child_path(path, i), elem, out); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	key := f.key(path)
+	if _, ok := out[key]; ok {
+		return &PathError{Path: path, Err: fmt.Errorf("%w: %q", ErrKeyCollision, key)}
+	}
+	out[key] = v
+	return nil
+}
+
+func (f *flattener) key(path Path) string {
+	var sb strings.Builder
+	r := strings.NewReplacer("~", "~0", "/", "~1")
+	for i, e := range path {
+		if f.pointer {
+			sb.WriteByte('/')
+			sb.WriteString(r.Replace(fmt.Sprint(e)))
+			continue
+		}
+		if i > 0 {
+			sb.WriteString(f.sep)
+		}
+		fmt.Fprint(&sb, e)
+	}
+	return sb.String()
+}
+
+func (f *flattener) split(key string) ([]string, error) {
+	if key == "" {
+		return nil, nil
+	}
+	if !f.pointer {
+		return strings.Split(key, f.sep), nil
+	}
+
+	if key[0] != '/' {
+		return nil, fmt.Errorf("%w: json pointer %q must start with /", ErrParseFailed, key)
+	}
+	segs := strings.Split(key[1:], "/")
+	r := strings.NewReplacer("~1", "/", "~0", "~")
+	for i, seg := range segs {
+		segs[i] = r.Replace(seg)
+	}
+	return segs, nil
+}
+
+// 展开过程中创建的中间层，与flat中本身是map的值区分
+type flatBranch map[string]interface{}
+
+// Flatten的逆操作，按key重建嵌套结构后按Decode解码到dst，dst可以是类型化的结构，也可以是*map[string]interface{}、*interface{}
+// 下标连续（0到n-1）的一层重建为slice，其余重建为map；key的前缀同时是叶子时返回ErrKeyCollision
+func Unflatten(flat map[string]interface{}, dst interface{}, opts ...FlattenOption) error {
+	f := new_flattener(opts)
+
+	keys := make([]string, 0, len(flat))
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	// 按固定顺序重建，冲突时的错误稳定
+	sort.Strings(keys)
+
+	var root interface{}
+	for _, k := range keys {
+		segs, err := f.split(k)
+		if err != nil {
+			return &PathError{Path: Path{k}, Err: err}
+		}
+		if err := unflatten_set(&root, segs, flat[k]); err != nil {
+			return &PathError{Path: Path{k}, Err: err}
+		}
+	}
+	return Decode(unflatten_build(root), dst, f.do...)
+}
+
+func unflatten_set(root *interface{}, segs []string, v interface{}) error {
+	if len(segs) == 0 {
+		if *root != nil {
+			return ErrKeyCollision
+		}
+		*root = v
+		return nil
+	}
+
+	if *root == nil {
+		*root = flatBranch{}
+	}
+	branch, ok := (*root).(flatBranch)
+	if !ok {
+		return ErrKeyCollision
+	}
+	for _, seg := range segs[:len(segs)-1] {
+		next, exists := branch[seg]
+		if !exists {
+			nb := flatBranch{}
+			branch[seg] = nb
+			branch = nb
+			continue
+		}
+		if branch, ok = next.(flatBranch); !ok {
+			return ErrKeyCollision
+		}
+	}
+
+	last := segs[len(segs)-1]
+	if _, exists := branch[last]; exists {
+		return ErrKeyCollision
+	}
+	branch[last] = v
+	return nil
+}
+
+// 将中间层转换为map[string]interface{}或[]interface{}
+func unflatten_build(v interface{}) interface{} {
+	branch, ok := v.(flatBranch)
+	if !ok {
+		return v
+	}
+
+	if s, ok := branch.slice(); ok {
+		for i := range s {
+			s[i] = unflatten_build(s[i])
+		}
+		return s
+	}
+	m := make(map[string]interface{}, len(branch))
+	for k, elem := range branch {
+		m[k] = unflatten_build(elem)
+	}
+	return m
+}
+
+// key恰好是0到n-1时按下标排列
+func (fb flatBranch) slice() ([]interface{}, bool) {
+	s := make([]interface{}, len(fb))
+	for k, elem := range fb {
+		i, err := strconv.Atoi(k)
+		if err != nil || i < 0 || i >= len(s) || strconv.Itoa(i) != k {
+			return nil, false
+		}
+		s[i] = elem
+	}
+	return s, true
+}
@@ -0,0 +1,155 @@
+package reflect_walker
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func Test_Flatten(t *testing.T) {
+	type replica struct {
+		Host string `json:"host"`
+		Port int    `json:"port"`
+	}
+	type db struct {
+		Primary  *replica          `json:"primary"`
+		Replicas []replica         `json:"replicas"`
+		Labels   map[string]string `json:"labels"`
+		Shards   map[string]int    `json:"shards"`
+		Tags     []string          `json:"tags"`
+	}
+	type config struct {
+		Name string `json:"name"`
+		DB   db     `json:"db"`
+	}
+
+	input := config{
+		Name: "svc",
+		DB: db{
+			Primary:  &replica{Host: "p", Port: 1},
+			Replicas: []replica{{Host: "a", Port: 2}, {Host: "b", Port: 3}},
+			Labels:   map[string]string{"a/b": "x", "c~d": "y"},
+			Shards:   map[string]int{"0": 10, "1": 11},
+			Tags:     []string{},
+		},
+	}
+
+	testCases := []struct {
+		name   string
+		input  interface{}
+		opts   []FlattenOption
+		expect map[string]interface{}
+		err    error
+	}{
+		{
+			"点号分隔",
+			input,
+			nil,
+			map[string]interface{}{
+				"name":               "svc",
+				"db.primary.host":    "p",
+				"db.primary.port":    1,
+				"db.replicas.0.host": "a",
+				"db.replicas.0.port": 2,
+				"db.replicas.1.host": "b",
+				"db.replicas.1.port": 3,
+				"db.labels.a/b":      "x",
+				"db.labels.c~d":      "y",
+				"db.shards.0":        10,
+				"db.shards.1":        11,
+				"db.tags":            []interface{}{},
+			},
+			nil,
+		},
+		{
+			"JSON Pointer",
+			input,
+			[]FlattenOption{WithJSONPointerKeys()},
+			map[string]interface{}{
+				"/name":               "svc",
+				"/db/primary/host":    "p",
+				"/db/primary/port":    1,
+				"/db/replicas/0/host": "a",
+				"/db/replicas/0/port": 2,
+				"/db/replicas/1/host": "b",
+				"/db/replicas/1/port": 3,
+				"/db/labels/a~1b":     "x",
+				"/db/labels/c~0d":     "y",
+				"/db/shards/0":        10,
+				"/db/shards/1":        11,
+				"/db/tags":            []interface{}{},
+			},
+			nil,
+		},
+		{"根是字面量", 5, nil, map[string]interface{}{"": 5}, nil},
+		{"展开后key冲突", map[string]interface{}{"a.b": 1, "a": map[string]interface{}{"b": 2}}, nil, nil, ErrKeyCollision},
+	}
+
+	for _, tc := range testCases {
+		got, err := Flatten(tc.input, tc.opts...)
+		if !errors.Is(err, tc.err) || !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("%s miss match: \n\tinput: %#v\n\texpect:%v, %v\n\tgot:   %v, %v", tc.name, tc.input, tc.expect, tc.err, got, err)
+		}
+	}
+
+	// 往返
+	for _, opts := range [][]FlattenOption{nil, {WithJSONPointerKeys()}, {WithFlattenSeparator("__")}} {
+		flat, err := Flatten(input, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got config
+		if err := Unflatten(flat, &got, opts...); err != nil || !reflect.DeepEqual(got, input) {
+			t.Errorf("往返 miss match: \n\tinput: %v\n\texpect:%+v\n\tgot:   %+v, %v", flat, input, got, err)
+		}
+	}
+}
+
+func Test_Unflatten(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  map[string]interface{}
+		opts   []FlattenOption
+		expect interface{}
+		err    error
+	}{
+		{
+			"重建通用结构",
+			map[string]interface{}{"db.replicas.0.host": "a", "db.replicas.1.host": "b", "db.name": "x", "env": "prod"},
+			nil,
+			map[string]interface{}{
+				"db": map[string]interface{}{
+					"replicas": []interface{}{map[string]interface{}{"host": "a"}, map[string]interface{}{"host": "b"}},
+					"name":     "x",
+				},
+				"env": "prod",
+			},
+			nil,
+		},
+		{
+			"下标不连续时保留为map",
+			map[string]interface{}{"ids.0": 1, "ids.2": 3, "ids.01": 4},
+			nil,
+			map[string]interface{}{"ids": map[string]interface{}{"0": 1, "2": 3, "01": 4}},
+			nil,
+		},
+		{
+			"自定义分隔符",
+			map[string]interface{}{"DB__HOST": "h"},
+			[]FlattenOption{WithFlattenSeparator("__")},
+			map[string]interface{}{"DB": map[string]interface{}{"HOST": "h"}},
+			nil,
+		},
+		{"前缀同时是叶子", map[string]interface{}{"a": 1, "a.b": 2}, nil, nil, ErrKeyCollision},
+		{"空map值不能再展开", map[string]interface{}{"a": map[string]interface{}{}, "a.b": 2}, nil, nil, ErrKeyCollision},
+		{"JSON Pointer必须以/开头", map[string]interface{}{"a": 1}, []FlattenOption{WithJSONPointerKeys()}, nil, ErrParseFailed},
+	}
+
+	for _, tc := range testCases {
+		var got interface{}
+		err := Unflatten(tc.input, &got, tc.opts...)
+		if !errors.Is(err, tc.err) || !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("%s miss match: \n\tinput: %v\n\texpect:%v, %v\n\tgot:   %v, %v", tc.name, tc.input, tc.expect, tc.err, got, err)
+		}
+	}
+}
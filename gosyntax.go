@@ -0,0 +1,483 @@
+package reflect_walker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/token"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNotRepresentable = errors.New("value cannot be represented as Go source")
+
+type GoSyntaxOption func(g *goSyntax)
+
+// 输出的代码所在包的导入路径，该包内的类型名不带包名，并且输出其非公有字段
+func WithGoPackage(pkgPath string) GoSyntaxOption {
+	return func(g *goSyntax) {
+		g.pkgPath = pkgPath
+	}
+}
+
+// 遍历选项，如WithLeafTypes、WithMaxDepth，其中的routine不会执行
+func WithGoSyntaxWalkOptions(wo ...WalkOption) GoSyntaxOption {
+	return func(g *goSyntax) {
+		g.wo = append(g.wo, wo...)
+	}
+}
+
+type goSyntax struct {
+	pkgPath string
+	wo      []WalkOption
+
+	tr     *walker
+	ctx    context.Context
+	guard  cycleGuard
+	sb     strings.Builder
+	levels []goLevel
+	bare   *scope // 从该scope开始的值不是容器的成员，如map的key
+}
+
+// 正在输出的容器
+type goLevel struct {
+	kind    reflect.Kind
+	v       reflect.Value // 容器的值，struct用于判断字段是否为零值
+	fields  []fieldPlan
+	next    int // 下一个待匹配的字段
+	members int
+	closers int // 指向字面量的指针的层数，容器结束后输出"; return &v }()"
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	goStringerType = reflect.TypeOf((*fmt.GoStringer)(nil)).Elem()
+)
+
+// 将v输出为可以编译的Go表达式，如`&pkg.Config{Name: "x", Ports: []int{80}}`，用于生成测试数据
+//   - 具名类型带包名，具名的字面量及interface{}中非默认类型的数值带类型转换，如pkg.Port(80)、int64(1)
+//   - 指向字面量的指针输出为func() *T { v := ...; return &v }()
+//   - map按key排序，struct省略零值字段，time.Time输出为time.Date(...)
+//   - 只有WithGoPackage指定的包内类型会输出非公有字段，其他包的非公有字段无法赋值，被省略
+//   - 与Dump一样按遍历选项处理，叶子类型与Walkable作为整体输出，需要实现fmt.GoStringer
+//
+// 输出中用到的包（如time、math）需要自行导入
+// func、chan、unsafe.Pointer的非nil值，其他包的非公有类型，以及超过WithMaxDepth的值返回ErrNotRepresentable，
+// 指针、map、slice形成的环返回ErrCycle
+func GoSyntax(v interface{}, opts ...GoSyntaxOption) (string, error) {
+	g := &goSyntax{}
+	for _, option := range opts {
+		option(g)
+	}
+
+	tw := NewTreeWalker(append([]WalkOption{WithSortedMaps()}, g.wo...)...).(*walker)
+	tw.routines = []Node_routine{g.node}
+	tw.parallelism = 0
+	tw.copying = true
+	tw.private = func(t reflect.Type) bool { return t.PkgPath() == g.pkgPath }
+	ctx, state := tw.begin(context.Background())
+	g.tr, g.ctx, g.guard = tw, ctx, cycleGuard{tr: tw, ctx: ctx}
+
+	g.run(root_scope(), v)
+	if state.err != nil {
+		return "", state.err
+	}
+	return g.sb.String(), nil
+}
+
+// 在引擎上输出in，不作为容器的成员
+func (g *goSyntax) run(sc *scope, in interface{}) {
+	bare := g.bare
+	g.bare = sc
+	e := &engine{tr: g.tr, ctx: g.ctx, obs: g}
+	e.start(sc, in)
+	for node := e.next(); node != nil; node = e.next() {
+		e.resume(g.tr.run_routines(e.ctx, node))
+	}
+	g.bare = bare
+}
+
+func (g *goSyntax) enter(sc *scope, in interface{}) bool {
+	if sc != g.bare && len(g.levels) > 0 && !g.member(sc, sc.elem) {
+		return false
+	}
+	return g.open(sc, in)
+}
+
+func (g *goSyntax) leave() {
+	level := g.levels[len(g.levels)-1]
+	g.levels = g.levels[:len(g.levels)-1]
+	g.guard.leave()
+	g.sb.WriteByte('}')
+	g.close(level.closers)
+}
+
+func (g *goSyntax) too_deep(sc *scope, in interface{}) {
+	g.fail(sc, fmt.Errorf("%w: beyond max depth", ErrNotRepresentable))
+}
+
+// 输出容器中字面量成员，容器成员已在enter中输出
+func (g *goSyntax) node(ctx context.Context, node TreeNode) {
+	tn := node.(*treeNode)
+	val := tn.nValue.(*treeVariable).rvalue()
+	if tn.nType == NodeType_literal || !g.tr.is_literal(&val) {
+		return
+	}
+
+	sc := enter_path(tn.parent, tn.elem)
+	var label interface{} = tn.elem
+	switch tn.nType {
+	case NodeType_slice_member:
+		sc, label = enter_path(tn.parent, tn.index), tn.index
+	case NodeType_map_pair:
+		sc, label = enter_path(tn.parent, tn.mapKey.Interface()), tn.mapKey.Interface()
+	}
+	if !g.member(sc, label) {
+		return
+	}
+	var in interface{}
+	if val.Kind() != reflect.Interface || !val.IsNil() {
+		in = val.Interface()
+	}
+	g.open(sc, in)
+}
+
+// 开始输出容器的一个成员，零值的struct字段返回false
+func (g *goSyntax) member(sc *scope, label interface{}) bool {
+	level := &g.levels[len(g.levels)-1]
+	var field *fieldPlan
+	if level.kind == reflect.Struct {
+		if field = level.field(label); field == nil || level.v.Field(field.index).IsZero() {
+			return false
+		}
+	}
+
+	if level.members > 0 {
+		g.sb.WriteString(", ")
+	}
+	level.members++
+	switch {
+	case field != nil:
+		fmt.Fprintf(&g.sb, "%s: ", field.field.Name)
+	case level.kind == reflect.Map:
+		g.run(sc.parent, label)
+		g.sb.WriteString(": ")
+	}
+	return true
+}
+
+// struct成员elem的字段，字段按顺序遍历，从上一个匹配的字段之后查找
+func (l *goLevel) field(elem interface{}) *fieldPlan {
+	for i := l.next; i < len(l.fields); i++ {
+		if l.fields[i].elem == elem {
+			l.next = i + 1
+			return &l.fields[i]
+		}
+	}
+	return nil
+}
+
+// 输出in，容器压栈并返回true，其成员由引擎遍历
+func (g *goSyntax) open(sc *scope, in interface{}) bool {
+	if in == nil {
+		g.sb.WriteString("nil")
+		return false
+	}
+
+	v := reflect.ValueOf(in)
+	closers := 0
+	for v.Kind() == reflect.Pointer && !g.whole(v.Type()) {
+		if v.IsNil() {
+			g.sb.WriteString(g.conversion(sc, v.Type(), "nil"))
+			g.close(closers)
+			return false
+		}
+		elem := v.Elem()
+		if g.composite(elem) {
+			// 复合字面量可以直接取地址
+			g.sb.WriteByte('&')
+			v = elem
+			break
+		}
+		fmt.Fprintf(&g.sb, "func() %s { v := ", g.type_of(sc, v.Type()))
+		closers++
+		if elem.Kind() == reflect.Interface {
+			if elem.IsNil() {
+				g.sb.WriteString("nil")
+				g.close(closers)
+				return false
+			}
+			elem = elem.Elem()
+		}
+		v = elem
+	}
+
+	t := v.Type()
+	level := goLevel{kind: t.Kind(), v: v, closers: closers}
+	switch {
+	case g.whole(t) || g.tr.plan(reflect.TypeOf(in)).walkable:
+		g.write_whole(sc, v)
+	case t.Kind() == reflect.Struct:
+		level.fields = g.tr.plan(t).fields
+		return g.push(sc, in, level)
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Map:
+		if v.IsNil() {
+			g.sb.WriteString(g.conversion(sc, t, "nil"))
+		} else if is_bytes(v) {
+			g.sb.WriteString(g.conversion(sc, t, strconv.Quote(string(v.Bytes()))))
+		} else {
+			return g.push(sc, in, level)
+		}
+	case t.Kind() == reflect.Array:
+		return g.push(sc, in, level)
+	case t.Kind() == reflect.Func || t.Kind() == reflect.Chan || t.Kind() == reflect.UnsafePointer:
+		if !v.IsNil() {
+			g.fail(sc, fmt.Errorf("%w: %s", ErrNotRepresentable, t))
+			return false
+		}
+		g.sb.WriteString(g.conversion(sc, t, "nil"))
+	default:
+		g.write_basic(sc, v)
+	}
+	g.close(closers)
+	return false
+}
+
+func (g *goSyntax) push(sc *scope, in interface{}, level goLevel) bool {
+	if !g.guard.enter(sc, in) {
+		return false
+	}
+	g.sb.WriteString(g.type_of(sc, level.v.Type()))
+	g.sb.WriteByte('{')
+	g.levels = append(g.levels, level)
+	return true
+}
+
+// 可以直接取地址的复合字面量
+func (g *goSyntax) composite(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Struct, reflect.Array:
+		return !g.whole(v.Type())
+	case reflect.Slice, reflect.Map:
+		return !v.IsNil()
+	}
+	return false
+}
+
+// 作为整体输出的类型：time.Time、叶子类型与Walkable
+func (g *goSyntax) whole(t reflect.Type) bool {
+	p := g.tr.plan(t)
+	return t == timeType || p.leaf || p.walkable
+}
+
+// 作为整体输出，time.Time输出为time.Date(...)，其他类型使用GoString
+func (g *goSyntax) write_whole(sc *scope, v reflect.Value) {
+	switch {
+	case !v.CanInterface():
+		g.fail(sc, fmt.Errorf("%w: unexported %s", ErrNotRepresentable, v.Type()))
+	case v.Type() == timeType:
+		g.write_time(v.Interface().(time.Time))
+	case v.Type().Implements(goStringerType):
+		g.sb.WriteString(v.Interface().(fmt.GoStringer).GoString())
+	default:
+		g.fail(sc, fmt.Errorf("%w: %s is walked as a whole and does not implement fmt.GoStringer", ErrNotRepresentable, v.Type()))
+	}
+}
+
+// 指向字面量的指针的结尾
+func (g *goSyntax) close(closers int) {
+	for i := 0; i < closers; i++ {
+		g.sb.WriteString("; return &v }()")
+	}
+}
+
+func (g *goSyntax) fail(sc *scope, err error) {
+	g.tr.report(g.ctx, &PathError{Path: sc.path(0), Err: err})
+}
+
+func (g *goSyntax) write_time(tm time.Time) {
+	loc := "time.UTC"
+	switch name, offset := tm.Zone(); {
+	case tm.Location() == time.Local:
+		loc = "time.Local"
+	case tm.Location() == time.UTC:
+	default:
+		loc = fmt.Sprintf("time.FixedZone(%q, %d)", name, offset)
+	}
+	fmt.Fprintf(&g.sb, "time.Date(%d, time.%s, %d, %d, %d, %d, %d, %s)",
+		tm.Year(), tm.Month(), tm.Day(), tm.Hour(), tm.Minute(), tm.Second(), tm.Nanosecond(), loc)
+}
+
+// 字面量，非默认类型带类型转换
+func (g *goSyntax) write_basic(sc *scope, v reflect.Value) {
+	t := v.Type()
+	var lit string
+	untyped := false // 无类型常量的默认类型与t相同
+	switch k := v.Kind(); {
+	case k == reflect.Bool:
+		lit, untyped = strconv.FormatBool(v.Bool()), k == reflect.Bool
+	case is_int_kind(k):
+		lit, untyped = strconv.FormatInt(v.Int(), 10), k == reflect.Int
+	case is_uint_kind(k):
+		lit = strconv.FormatUint(v.Uint(), 10)
+	case is_float_kind(k):
+		lit, untyped = format_float(v.Float(), t.Bits()), k == reflect.Float64
+	case k == reflect.Complex64 || k == reflect.Complex128:
+		c := v.Complex()
+		lit = fmt.Sprintf("complex(%s, %s)", format_float(real(c), t.Bits()/2), format_float(imag(c), t.Bits()/2))
+		untyped = k == reflect.Complex128
+	case k == reflect.String:
+		lit, untyped = strconv.Quote(v.String()), true
+	}
+
+	if untyped && t.Name() == t.Kind().String() && t.PkgPath() == "" {
+		g.sb.WriteString(lit)
+		return
+	}
+	g.sb.WriteString(g.conversion(sc, t, lit))
+}
+
+func format_float(f float64, bits int) string {
+	switch {
+	case math.IsNaN(f):
+		return "math.NaN()"
+	case math.IsInf(f, 1):
+		return "math.Inf(1)"
+	case math.IsInf(f, -1):
+		return "math.Inf(-1)"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bits)
+	if !strings.ContainsAny(s, ".e") {
+		// 保持为浮点常量
+		s += ".0"
+	}
+	return s
+}
+
+// 类型转换，以*、func、chan开头的类型需要加括号
+func (g *goSyntax) conversion(sc *scope, t reflect.Type, expr string) string {
+	name := g.type_of(sc, t)
+	if strings.HasPrefix(name, "*") || strings.HasPrefix(name, "func") || strings.Contains(name, "chan") && t.Name() == "" {
+		name = "(" + name + ")"
+	}
+	return name + "(" + expr + ")"
+}
+
+// 类型名，无法在代码中写出时记录错误
+func (g *goSyntax) type_of(sc *scope, t reflect.Type) string {
+	name, err := g.type_name(t)
+	if err != nil {
+		g.fail(sc, err)
+	}
+	return name
+}
+
+// 类型名，WithGoPackage指定的包内类型不带包名，其他包的非公有类型无法写出
+func (g *goSyntax) type_name(t reflect.Type) (string, error) {
+	if t.Name() != "" {
+		if t.PkgPath() == "" || t.PkgPath() == g.pkgPath {
+			return t.Name(), nil
+		}
+		if !token.IsExported(t.Name()) {
+			return "", fmt.Errorf("%w: unexported type %s", ErrNotRepresentable, t)
+		}
+		// reflect输出的包名即源码中的默认包名
+		s := t.String()
+		return s[:strings.Index(s, ".")+1] + t.Name(), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem, err := g.type_name(t.Elem())
+		return "*" + elem, err
+	case reflect.Slice:
+		elem, err := g.type_name(t.Elem())
+		return "[]" + elem, err
+	case reflect.Array:
+		elem, err := g.type_name(t.Elem())
+		return fmt.Sprintf("[%d]%s", t.Len(), elem), err
+	case reflect.Map:
+		key, err := g.type_name(t.Key())
+		if err != nil {
+			return "", err
+		}
+		elem, err := g.type_name(t.Elem())
+		return fmt.Sprintf("map[%s]%s", key, elem), err
+	case reflect.Chan:
+		elem, err := g.type_name(t.Elem())
+		switch t.ChanDir() {
+		case reflect.RecvDir:
+			return "<-chan " + elem, err
+		case reflect.SendDir:
+			return "chan<- " + elem, err
+		}
+		return "chan " + elem, err
+	case reflect.Func:
+		return g.func_name(t)
+	case reflect.Struct:
+		return g.struct_name(t)
+	}
+	return t.String(), nil
+}
+
+// 匿名struct类型，字段类型同样按type_name输出
+func (g *goSyntax) struct_name(t reflect.Type) (string, error) {
+	if t.NumField() == 0 {
+		return "struct {}", nil
+	}
+	fields := make([]string, t.NumField())
+	for i := range fields {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && sf.PkgPath != g.pkgPath {
+			return "", fmt.Errorf("%w: unexported field %s of %s", ErrNotRepresentable, sf.Name, t)
+		}
+		ft, err := g.type_name(sf.Type)
+		if err != nil {
+			return "", err
+		}
+		fields[i] = ft
+		if !sf.Anonymous {
+			fields[i] = sf.Name + " " + ft
+		}
+		if sf.Tag != "" {
+			fields[i] += " " + strconv.Quote(string(sf.Tag))
+		}
+	}
+	return "struct { " + strings.Join(fields, "; ") + " }", nil
+}
+
+// 匿名func类型
+func (g *goSyntax) func_name(t reflect.Type) (string, error) {
+	in := make([]string, t.NumIn())
+	for i := range in {
+		name, err := g.type_name(t.In(i))
+		if err != nil {
+			return "", err
+		}
+		if t.IsVariadic() && i == len(in)-1 {
+			name = "..." + strings.TrimPrefix(name, "[]")
+		}
+		in[i] = name
+	}
+	out := make([]string, t.NumOut())
+	for i := range out {
+		name, err := g.type_name(t.Out(i))
+		if err != nil {
+			return "", err
+		}
+		out[i] = name
+	}
+
+	s := "func(" + strings.Join(in, ", ") + ")"
+	switch len(out) {
+	case 0:
+	case 1:
+		s += " " + out[0]
+	default:
+		s += " (" + strings.Join(out, ", ") + ")"
+	}
+	return s, nil
+}
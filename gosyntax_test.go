@@ -0,0 +1,114 @@
+package reflect_walker
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func Test_GoSyntax(t *testing.T) {
+	type Port uint16
+	type Config struct {
+		Name    string
+		Ports   []Port
+		Labels  map[string]string
+		Timeout *time.Duration
+		Parent  *Config
+		Ratio   float64
+		Extra   interface{}
+		Created time.Time
+		secret  string
+	}
+	type node struct {
+		Next *node
+	}
+	type registry struct {
+		byName  map[string]int
+		shards  []map[Port]bool
+		Visible bool
+	}
+
+	const pkg = "bournex/reflect_walker"
+	timeout := 3 * time.Second
+	name := "x"
+	loop := &node{}
+	loop.Next = loop
+	selfMap := map[string]interface{}{}
+	selfMap["self"] = selfMap
+	selfSlice := []interface{}{nil}
+	selfSlice[0] = selfSlice
+
+	testCases := []struct {
+		name   string
+		input  interface{}
+		opts   []GoSyntaxOption
+		expect string
+		err    error
+	}{
+		{"nil", nil, nil, "nil", nil},
+		{"默认类型字面量", []interface{}{1, 1.0, "a", true, complex(1, 2)}, nil, `[]interface {}{1, 1.0, "a", true, complex(1.0, 2.0)}`, nil},
+		{"非默认类型字面量", []interface{}{int64(1), float32(1.5), uint8(2), Port(80)}, []GoSyntaxOption{WithGoPackage(pkg)}, `[]interface {}{int64(1), float32(1.5), uint8(2), Port(80)}`, nil},
+		{"特殊浮点数", []float64{math.NaN(), math.Inf(-1), 1e21}, nil, `[]float64{math.NaN(), math.Inf(-1), 1e+21}`, nil},
+		{"字节切片", []byte("hi\n"), nil, `[]uint8("hi\n")`, nil},
+		{"nil值", []interface{}{(*int)(nil), []int(nil), map[string]int(nil), (func())(nil)}, nil, `[]interface {}{(*int)(nil), []int(nil), map[string]int(nil), (func())(nil)}`, nil},
+		{"map按key排序", map[string]int{"b": 2, "a": 1, "c": 3}, nil, `map[string]int{"a": 1, "b": 2, "c": 3}`, nil},
+		{"指向字面量的指针", &name, nil, `func() *string { v := "x"; return &v }()`, nil},
+		{
+			"同一包内的struct",
+			&Config{
+				Name:    "x",
+				Ports:   []Port{80},
+				Labels:  map[string]string{"b": "2", "a": "1"},
+				Timeout: &timeout,
+				Parent:  &Config{Name: "root"},
+				Ratio:   2,
+				Extra:   int32(7),
+				Created: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+				secret:  "s",
+			},
+			[]GoSyntaxOption{WithGoPackage(pkg)},
+			`&Config{Name: "x", Ports: []Port{Port(80)}, Labels: map[string]string{"a": "1", "b": "2"}, ` +
+				`Timeout: func() *time.Duration { v := time.Duration(3000000000); return &v }(), Parent: &Config{Name: "root"}, ` +
+				`Ratio: 2.0, Extra: int32(7), Created: time.Date(2024, time.January, 2, 3, 4, 5, 6, time.UTC), secret: "s"}`,
+			nil,
+		},
+		{
+			"非公有的map与slice字段",
+			registry{byName: map[string]int{"b": 2, "a": 1}, shards: []map[Port]bool{{2: true, 1: false}}, Visible: true},
+			[]GoSyntaxOption{WithGoPackage(pkg)},
+			`registry{byName: map[string]int{"a": 1, "b": 2}, shards: []map[Port]bool{map[Port]bool{Port(1): false, Port(2): true}}, Visible: true}`,
+			nil,
+		},
+		{"其他包的struct带包名且省略非公有字段", Config{Name: "x", secret: "s"}, nil, `reflect_walker.Config{Name: "x"}`, nil},
+		{"固定时区", time.Date(2024, 5, 6, 0, 0, 0, 0, time.FixedZone("CST", 8*3600)), nil, `time.Date(2024, time.May, 6, 0, 0, 0, 0, time.FixedZone("CST", 28800))`, nil},
+		{"func无法表示", Config{Extra: func() {}}, nil, "", ErrNotRepresentable},
+		{"其他包的非公有类型", errors.New("x"), nil, "", ErrNotRepresentable},
+		{
+			"匿名struct中的类型不带本包包名",
+			[]struct {
+				P Port `json:"p"`
+			}{{P: 1}},
+			[]GoSyntaxOption{WithGoPackage(pkg)},
+			`[]struct { P Port "json:\"p\"" }{struct { P Port "json:\"p\"" }{P: Port(1)}}`,
+			nil,
+		},
+		{"叶子类型使用GoString", []interface{}{goStringer{}}, []GoSyntaxOption{WithGoPackage(pkg), WithGoSyntaxWalkOptions(WithLeafTypes(goStringer{}))}, `[]interface {}{newGoStringer()}`, nil},
+		{"叶子类型未实现GoStringer", Config{Name: "x"}, []GoSyntaxOption{WithGoSyntaxWalkOptions(WithLeafTypes(Config{}))}, "", ErrNotRepresentable},
+		{"超过最大深度", [][]int{{1}}, []GoSyntaxOption{WithGoSyntaxWalkOptions(WithMaxDepth(0))}, "", ErrNotRepresentable},
+		{"指针环", loop, []GoSyntaxOption{WithGoPackage(pkg)}, "", ErrCycle},
+		{"map环", selfMap, nil, "", ErrCycle},
+		{"slice环", selfSlice, nil, "", ErrCycle},
+	}
+
+	for _, tc := range testCases {
+		got, err := GoSyntax(tc.input, tc.opts...)
+		if !errors.Is(err, tc.err) || got != tc.expect {
+			t.Errorf("%s miss match: \n\tinput: %#v\n\texpect:%s, %v\n\tgot:   %s, %v", tc.name, tc.input, tc.expect, tc.err, got, err)
+		}
+	}
+}
+
+type goStringer struct{ n int }
+
+func (goStringer) GoString() string { return "newGoStringer()" }
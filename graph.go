@@ -0,0 +1,322 @@
+package reflect_walker
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 叶子节点的显示方式
+type GraphLeaves int
+
+const (
+	GraphLeavesInline GraphLeaves = iota // 在所属容器的标签内逐行列出，默认
+	GraphLeavesNodes                     // 每个叶子是单独的节点，由容器指向它
+	GraphLeavesHidden                    // 不显示叶子，只保留容器之间的关系
+)
+
+type GraphOption func(g *grapher)
+
+// 最多展开的容器层数，更深的容器只显示类型与"…"，n<=0时不限制
+func WithGraphDepth(n int) GraphOption {
+	return func(g *grapher) {
+		g.depth = n
+	}
+}
+
+// 叶子节点的显示方式，默认GraphLeavesInline
+func WithGraphLeaves(mode GraphLeaves) GraphOption {
+	return func(g *grapher) {
+		g.leaves = mode
+	}
+}
+
+// 遍历选项，如WithLeafTypes，其中的routine不会执行
+func WithGraphWalkOptions(wo ...WalkOption) GraphOption {
+	return func(g *grapher) {
+		g.wo = append(g.wo, wo...)
+	}
+}
+
+// 将v的对象图输出为Graphviz DOT，用于调试指针共享复杂的内存结构
+// 每个struct、slice、map是一个节点，边以字段名、下标、key标记
+// 多处引用的同一指针、map、slice只输出一个节点，有多条入边，环也由此终止
+// 与Dump一样只包含公有字段，map按key排序，不会修改v
+//
+//	digraph G {
+//	  node [shape=box];
+//	  n0 [label="*main.config\nName: \"svc\""];
+//	  n1 [label="[]int len=2\n0: 80\n1: 443"];
+//	  n0 -> n1 [label="Ports"];
+//	}
+func DOT(w io.Writer, v interface{}, opts ...GraphOption) error {
+	g := build_graph(v, opts)
+	bw := bufio.NewWriter(w)
+	bw.WriteString("digraph G {\n  node [shape=box];\n")
+	for _, n := range g.nodes {
+		fmt.Fprintf(bw, "  n%d [label=\"%s\"];\n", n.id, dot_escape(n.lines, `\n`))
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(bw, "  n%d -> n%d [label=\"%s\"];\n", e.from, e.to, dot_escape([]string{e.label}, ""))
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// 与DOT相同，输出为Mermaid流程图
+//
+//	flowchart LR
+//	  n0["*main.config<br/>Name: #quot;svc#quot;"]
+//	  n1["[]int len=2<br/>0: 80<br/>1: 443"]
+//	  n0 -->|"Ports"| n1
+func Mermaid(w io.Writer, v interface{}, opts ...GraphOption) error {
+	g := build_graph(v, opts)
+	bw := bufio.NewWriter(w)
+	bw.WriteString("flowchart LR\n")
+	for _, n := range g.nodes {
+		fmt.Fprintf(bw, "  n%d[\"%s\"]\n", n.id, mermaid_escape(n.lines, "<br/>"))
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(bw, "  n%d -->|\"%s\"| n%d\n", e.from, mermaid_escape([]string{e.label}, ""), e.to)
+	}
+	return bw.Flush()
+}
+
+type grapher struct {
+	depth  int
+	leaves GraphLeaves
+	wo     []WalkOption
+
+	tr     *walker
+	nodes  []*graphNode
+	edges  []graphEdge
+	levels []graphLevel
+	seen   map[graphRef]int // 已输出的指针、map、slice对应的节点
+}
+
+type graphNode struct {
+	id    int
+	lines []string // 第一行是类型，之后是内联的叶子
+}
+
+type graphEdge struct {
+	from, to int
+	label    string
+}
+
+type graphLevel struct {
+	node  int
+	keyed bool // 成员以map或Walkable的key标识
+}
+
+type graphRef struct {
+	ptr uintptr
+	t   reflect.Type
+	len int // 同一底层数组上不同长度的slice是不同的节点
+}
+
+func build_graph(v interface{}, opts []GraphOption) *grapher {
+	g := &grapher{seen: map[graphRef]int{}}
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	tw := NewTreeWalker(append([]WalkOption{WithSortedMaps()}, g.wo...)...).(*walker)
+	tw.routines = []Node_routine{g.node}
+	tw.parallelism = 0
+	tw.copying = true
+	g.tr = tw
+
+	e := &engine{tr: tw, obs: g}
+	e.ctx, _ = tw.begin(context.Background())
+	e.start(root_scope(), v)
+	for node := e.next(); node != nil; node = e.next() {
+		e.resume(tw.run_routines(e.ctx, node))
+	}
+	return g
+}
+
+func (g *grapher) enter(sc *scope, in interface{}) bool {
+	label := ""
+	if len(g.levels) > 0 {
+		label = g.label(sc.elem)
+	}
+	if in == nil {
+		g.leaf(label, "nil")
+		return false
+	}
+
+	v := reflect.ValueOf(in)
+	title := v.Type().String()
+	ref, elem, ok := g.container(v)
+	if !ok {
+		g.leaf(label, dump_literal(elem))
+		return false
+	}
+
+	if ref.ptr != 0 {
+		if id, shared := g.seen[ref]; shared {
+			g.edge(label, id)
+			return false
+		}
+	}
+	switch elem.Kind() {
+	case reflect.Slice, reflect.Map:
+		title += fmt.Sprintf(" len=%d", elem.Len())
+	}
+
+	id := g.add(title)
+	if ref.ptr != 0 {
+		g.seen[ref] = id
+	}
+	if len(g.levels) > 0 {
+		g.edge(label, id)
+	}
+	if g.depth > 0 && len(g.levels) >= g.depth {
+		g.nodes[id].lines[0] += " …"
+		return false
+	}
+	g.levels = append(g.levels, graphLevel{node: id, keyed: g.keyed(elem)})
+	return true
+}
+
+// 超过WithMaxDepth的值作为叶子，只显示类型
+func (g *grapher) too_deep(sc *scope, in interface{}) {
+	label := ""
+	if len(g.levels) > 0 {
+		label = g.label(sc.elem)
+	}
+	if is_nil(in) {
+		g.leaf(label, "nil")
+		return
+	}
+	g.leaf(label, reflect.TypeOf(in).String()+" <max depth>")
+}
+
+func (g *grapher) leave() {
+	g.levels = g.levels[:len(g.levels)-1]
+}
+
+// 容器已在enter中输出，这里处理字面量成员
+func (g *grapher) node(ctx context.Context, node TreeNode) {
+	tn := node.(*treeNode)
+	tv := tn.nValue.(*treeVariable)
+	val := tv.rvalue()
+	if tn.nType == NodeType_literal || !g.tr.is_literal(&val) {
+		return
+	}
+
+	var label string
+	switch {
+	case tn.nType == NodeType_slice_member:
+		label = g.label(tn.index)
+	case tn.nType == NodeType_map_pair && tn.mapKey.IsValid():
+		label = g.label(tn.mapKey.Interface())
+	default:
+		label = g.label(tn.elem)
+	}
+	_, elem, _ := g.container(val)
+	g.leaf(label, dump_literal(elem))
+}
+
+// 与open_frame一样解开指针与interface，返回值的标识与解开后的值，不是容器时返回false
+func (g *grapher) container(v reflect.Value) (graphRef, reflect.Value, bool) {
+	var ref graphRef
+	for {
+		plan := g.tr.plan(v.Type())
+		if plan.walkable {
+			if is_nillable(v.Type()) && v.IsNil() {
+				return ref, v, false
+			}
+			if ref.ptr == 0 && v.Kind() == reflect.Pointer {
+				ref = graphRef{ptr: v.Pointer(), t: v.Type()}
+			}
+			return ref, v, true
+		}
+		if plan.leaf || plan.literal && v.Kind() != reflect.Interface {
+			return ref, v, false
+		}
+
+		switch v.Kind() {
+		case reflect.Pointer, reflect.Interface:
+			if v.IsNil() {
+				return ref, v, false
+			}
+			if ref.ptr == 0 && v.Kind() == reflect.Pointer {
+				ref = graphRef{ptr: v.Pointer(), t: v.Type()}
+			}
+			v = v.Elem()
+		case reflect.Map, reflect.Slice:
+			if v.IsNil() {
+				return ref, v, false
+			}
+			if ref.ptr == 0 && v.Len() > 0 {
+				ref = graphRef{ptr: v.Pointer(), t: v.Type(), len: v.Len()}
+			}
+			return ref, v, true
+		case reflect.Struct, reflect.Array:
+			return ref, v, true
+		default:
+			return ref, v, false
+		}
+	}
+}
+
+func (g *grapher) add(title string) int {
+	id := len(g.nodes)
+	g.nodes = append(g.nodes, &graphNode{id: id, lines: []string{title}})
+	return id
+}
+
+func (g *grapher) edge(label string, to int) {
+	g.edges = append(g.edges, graphEdge{from: g.levels[len(g.levels)-1].node, to: to, label: label})
+}
+
+// 叶子，作为根时是单独的节点
+func (g *grapher) leaf(label, text string) {
+	if len(g.levels) == 0 {
+		g.add(text)
+		return
+	}
+
+	switch g.leaves {
+	case GraphLeavesInline:
+		parent := g.nodes[g.levels[len(g.levels)-1].node]
+		parent.lines = append(parent.lines, label+": "+text)
+	case GraphLeavesNodes:
+		g.edge(label, g.add(text))
+	}
+}
+
+func (g *grapher) label(elem interface{}) string {
+	if s, ok := elem.(string); ok && g.levels[len(g.levels)-1].keyed {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(elem)
+}
+
+func (g *grapher) keyed(v reflect.Value) bool {
+	return v.Kind() == reflect.Map || g.tr.plan(v.Type()).walkable
+}
+
+func dot_escape(lines []string, sep string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	escaped := make([]string, len(lines))
+	for i, line := range lines {
+		escaped[i] = r.Replace(line)
+	}
+	return strings.Join(escaped, sep)
+}
+
+func mermaid_escape(lines []string, sep string) string {
+	r := strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;", "\n", "<br/>")
+	escaped := make([]string, len(lines))
+	for i, line := range lines {
+		escaped[i] = r.Replace(line)
+	}
+	return strings.Join(escaped, sep)
+}
@@ -0,0 +1,128 @@
+package reflect_walker
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Graph(t *testing.T) {
+	type node struct {
+		Name  string
+		Next  *node
+		Tags  []string
+		Attrs map[string]int
+	}
+
+	a := &node{Name: "a", Attrs: map[string]int{"w": 1}}
+	b := &node{Name: `b"<`, Next: a, Tags: []string{"x"}}
+	a.Next = b
+	shared := []interface{}{a, b, nil}
+
+	testCases := []struct {
+		name    string
+		input   interface{}
+		opts    []GraphOption
+		dot     string
+		mermaid string
+	}{
+		{
+			"共享指针与环",
+			shared,
+			nil,
+			`digraph G {
+  node [shape=box];
+  n0 [label="[]interface {} len=3\n2: nil"];
+  n1 [label="*reflect_walker.node\nName: \"a\"\nTags: nil"];
+  n2 [label="*reflect_walker.node\nName: \"b\\\"<\"\nAttrs: nil"];
+  n3 [label="[]string len=1\n0: \"x\""];
+  n4 [label="map[string]int len=1\n\"w\": 1"];
+  n0 -> n1 [label="0"];
+  n1 -> n2 [label="Next"];
+  n2 -> n1 [label="Next"];
+  n2 -> n3 [label="Tags"];
+  n1 -> n4 [label="Attrs"];
+  n0 -> n2 [label="1"];
+}
+`,
+			`flowchart LR
+  n0["[]interface {} len=3<br/>2: nil"]
+  n1["*reflect_walker.node<br/>Name: #quot;a#quot;<br/>Tags: nil"]
+  n2["*reflect_walker.node<br/>Name: #quot;b\#quot;#lt;#quot;<br/>Attrs: nil"]
+  n3["[]string len=1<br/>0: #quot;x#quot;"]
+  n4["map[string]int len=1<br/>#quot;w#quot;: 1"]
+  n0 -->|"0"| n1
+  n1 -->|"Next"| n2
+  n2 -->|"Next"| n1
+  n2 -->|"Tags"| n3
+  n1 -->|"Attrs"| n4
+  n0 -->|"1"| n2
+`,
+		},
+		{
+			"限制层数并隐藏叶子",
+			shared,
+			[]GraphOption{WithGraphDepth(1), WithGraphLeaves(GraphLeavesHidden)},
+			`digraph G {
+  node [shape=box];
+  n0 [label="[]interface {} len=3"];
+  n1 [label="*reflect_walker.node …"];
+  n2 [label="*reflect_walker.node …"];
+  n0 -> n1 [label="0"];
+  n0 -> n2 [label="1"];
+}
+`,
+			`flowchart LR
+  n0["[]interface {} len=3"]
+  n1["*reflect_walker.node …"]
+  n2["*reflect_walker.node …"]
+  n0 -->|"0"| n1
+  n0 -->|"1"| n2
+`,
+		},
+		{
+			"叶子作为节点",
+			map[string]interface{}{"k": 1},
+			[]GraphOption{WithGraphLeaves(GraphLeavesNodes)},
+			`digraph G {
+  node [shape=box];
+  n0 [label="map[string]interface {} len=1"];
+  n1 [label="1"];
+  n0 -> n1 [label="\"k\""];
+}
+`,
+			`flowchart LR
+  n0["map[string]interface {} len=1"]
+  n1["1"]
+  n0 -->|"#quot;k#quot;"| n1
+`,
+		},
+		{
+			"超过最大深度",
+			[]interface{}{&node{Name: "a", Tags: []string{"x"}}},
+			[]GraphOption{WithGraphWalkOptions(WithMaxDepth(1))},
+			`digraph G {
+  node [shape=box];
+  n0 [label="[]interface {} len=1"];
+  n1 [label="*reflect_walker.node\nName: \"a\"\nNext: nil\nTags: []string <max depth>\nAttrs: nil"];
+  n0 -> n1 [label="0"];
+}
+`,
+			`flowchart LR
+  n0["[]interface {} len=1"]
+  n1["*reflect_walker.node<br/>Name: #quot;a#quot;<br/>Next: nil<br/>Tags: []string #lt;max depth#gt;<br/>Attrs: nil"]
+  n0 -->|"0"| n1
+`,
+		},
+		{"根是字面量", 5, nil, "digraph G {\n  node [shape=box];\n  n0 [label=\"5\"];\n}\n", "flowchart LR\n  n0[\"5\"]\n"},
+	}
+
+	for _, tc := range testCases {
+		var dot, mermaid strings.Builder
+		if err := DOT(&dot, tc.input, tc.opts...); err != nil || dot.String() != tc.dot {
+			t.Errorf("%s miss match: \n\tinput: %#v\n\texpect:%s\n\tgot:   %s, %v", tc.name, tc.input, tc.dot, dot.String(), err)
+		}
+		if err := Mermaid(&mermaid, tc.input, tc.opts...); err != nil || mermaid.String() != tc.mermaid {
+			t.Errorf("%s miss match: \n\tinput: %#v\n\texpect:%s\n\tgot:   %s, %v", tc.name, tc.input, tc.mermaid, mermaid.String(), err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package reflect_walker
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+var ErrKeyCollision = errors.New("key collision")
+
+// key转换函数
+type KeyTransform func(key string) string
+
+// 对map中字符串类型的key做转换，在routine之前执行，routine看到的是转换后的key
+// 与WithJsonableMap同时使用时，struct会被转换为map[string]interface{}，字段名（优先取json标签）同样被转换，
+// 此时struct成员也支持Key().Set改名与Delete
+// 多个key转换后相同时，保留未经改名的key，其余丢弃，并通过WalkWithError返回ErrKeyCollision
+func WithKeyTransform(fn KeyTransform) WalkOption {
+	return func(tw *walker) {
+		tw.keyTransform = fn
+	}
+}
+
+// 拆分单词：按非字母数字字符，以及大小写边界拆分，如"HTTPServerID"拆分为http、server、id
+func split_words(s string) []string {
+	var (
+		words []string
+		cur   []rune
+	)
+	runes := []rune(s)
+	flush := func() {
+		if len(cur) > 0 {
+			words = append(words, strings.ToLower(string(cur)))
+			cur = cur[:0]
+		}
+	}
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && len(cur) > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(prev) || nextLower {
+				flush()
+			}
+		}
+		cur = append(cur, r)
+	}
+	flush()
+	return words
+}
+
+// user_name
+func SnakeCase(key string) string {
+	return strings.Join(split_words(key), "_")
+}
+
+// user-name
+func KebabCase(key string) string {
+	return strings.Join(split_words(key), "-")
+}
+
+// userName
+func CamelCase(key string) string {
+	words := split_words(key)
+	for i := 1; i < len(words); i++ {
+		r := []rune(words[i])
+		r[0] = unicode.ToUpper(r[0])
+		words[i] = string(r)
+	}
+	return strings.Join(words, "")
+}
+
+// 对key应用转换，返回是否发生改变
+func (tr *walker) transform_key(key reflect.Value) (reflect.Value, bool) {
+	if tr.keyTransform == nil || key.Kind() != reflect.String {
+		return key, false
+	}
+	nk := tr.keyTransform(key.String())
+	if nk == key.String() {
+		return key, false
+	}
+	return reflect.ValueOf(nk).Convert(key.Type()), true
+}
+
+// 记录容器中改过名的key，用于冲突检测
+type keyTracker struct {
+	renamed map[interface{}]struct{}
+}
+
+// 判断key能否写入m，发生冲突时未改名的key优先，冲突会被记录为错误
+func (kt *keyTracker) admit(ctx context.Context, tr *walker, m reflect.Value, key reflect.Value, renamed bool, path func() Path) bool {
+	if !renamed && len(kt.renamed) == 0 {
+		return true
+	}
+
+	admit := true
+	if m.MapIndex(key).IsValid() {
+		_, existingRenamed := kt.renamed[key.Interface()]
+		tr.report(ctx, &PathError{Path: path(), Err: ErrKeyCollision})
+		// 已有的key未改名，或二者都改过名时保留先写入的
+		admit = !renamed && existingRenamed
+	}
+
+	if admit && renamed {
+		if kt.renamed == nil {
+			kt.renamed = map[interface{}]struct{}{}
+		}
+		kt.renamed[key.Interface()] = struct{}{}
+	} else if admit {
+		delete(kt.renamed, key.Interface())
+	}
+	return admit
+}
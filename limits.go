@@ -0,0 +1,174 @@
+package reflect_walker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"unicode/utf8"
+)
+
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// 遍历不可信输入时的资源限制，为0的项不限制
+type Limits struct {
+	MaxNodes    int64 // 容器成员（slice成员、map键值对、struct字段、Walkable子节点）的总数
+	MaxElements int   // 单个slice、map、Walkable的成员数
+	MaxString   int   // 单个字符串或[]byte的字节数，map的key同样检查
+	MaxBytes    int64 // 遍历过的字符串与[]byte的总字节数，含map的key
+
+	// 超出限制时截断而不是停止遍历：
+	//   - 超出MaxElements的成员被丢弃，map按遍历顺序保留，需要确定的结果时配合WithSortedMaps
+	//   - 超出MaxString的字符串截断到限制以内，不会截断在UTF-8字符中间
+	//   - 超出MaxNodes后剩余的成员被丢弃，原地修改的struct字段保留原值
+	//   - 超出MaxBytes后的字符串截断到剩余的字节数
+	//   - map的key超出MaxString或MaxBytes时整个键值对被丢弃
+	// 并发遍历时MaxNodes、MaxBytes截断的是哪些成员不确定
+	Truncate bool
+}
+
+// 超出的资源限制，通过PathError返回，Path为超出限制的位置
+type LimitError struct {
+	Limit string // Limits中的字段名，如MaxElements
+	Max   int64
+}
+
+func (le *LimitError) Error() string {
+	return fmt.Sprintf("%v: %s %d", ErrLimitExceeded, le.Limit, le.Max)
+}
+
+func (le *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// 限制遍历的资源，未设置Truncate时超出限制立即停止遍历，未遍历的部分原样保留，WalkWithError返回*LimitError
+// 检查在重建容器之前进行，超大的输入不会先被完整复制；开启WithSortedMaps时map需要取出全部key排序后再截断
+func WithLimits(l Limits) WalkOption {
+	return func(tw *walker) {
+		tw.limits = &l
+	}
+}
+
+type limitAction int
+
+const (
+	limit_ok   limitAction = iota // 未超出
+	limit_cut                     // 截断，使用截断后的值
+	limit_drop                    // 截断，丢弃当前成员
+	limit_stop                    // 停止遍历
+)
+
+// 超出限制，截断或停止遍历
+func (tr *walker) exceed(ctx context.Context, limit string, max int64, path func() Path) limitAction {
+	if tr.limits.Truncate {
+		return limit_drop
+	}
+	if state, ok := ctx.Value(stateCtxKey).(*walkState); ok {
+		state.stopped.Store(true)
+	}
+	tr.report(ctx, &PathError{Path: path(), Err: &LimitError{Limit: limit, Max: max}})
+	return limit_stop
+}
+
+// 容器的成员数，截断时返回限制以内的数量
+func (tr *walker) limit_elements(ctx context.Context, sc *scope, n int) (int, limitAction) {
+	if tr.limits == nil || tr.limits.MaxElements <= 0 || n <= tr.limits.MaxElements {
+		return n, limit_ok
+	}
+	max := tr.limits.MaxElements
+	if tr.exceed(ctx, "MaxElements", int64(max), func() Path { return sc.path(0) }) == limit_stop {
+		return n, limit_stop
+	}
+	return max, limit_ok
+}
+
+// 计入一个容器成员
+func (tr *walker) count_node(ctx context.Context, path func() Path) limitAction {
+	if tr.limits == nil || tr.limits.MaxNodes <= 0 {
+		return limit_ok
+	}
+	state, ok := ctx.Value(stateCtxKey).(*walkState)
+	if !ok || state.nodes.Add(1) <= tr.limits.MaxNodes {
+		return limit_ok
+	}
+	return tr.exceed(ctx, "MaxNodes", tr.limits.MaxNodes, path)
+}
+
+// 检查字符串与[]byte的长度，截断时返回截断后的值
+func (tr *walker) limit_bytes(ctx context.Context, val reflect.Value, path func() Path) (reflect.Value, limitAction) {
+	if tr.limits == nil || !val.IsValid() || (tr.limits.MaxString <= 0 && tr.limits.MaxBytes <= 0) {
+		return val, limit_ok
+	}
+	if val.Kind() != reflect.String && (val.Kind() != reflect.Slice || val.Type().Elem().Kind() != reflect.Uint8) {
+		return val, limit_ok
+	}
+
+	n := val.Len()
+	keep := n
+	if max := tr.limits.MaxString; max > 0 && n > max {
+		if tr.exceed(ctx, "MaxString", int64(max), path) == limit_stop {
+			return val, limit_stop
+		}
+		keep = max
+	}
+	if max := tr.limits.MaxBytes; max > 0 {
+		if state, ok := ctx.Value(stateCtxKey).(*walkState); ok {
+			if total := state.bytes.Add(int64(keep)); total > max {
+				if tr.exceed(ctx, "MaxBytes", max, path) == limit_stop {
+					return val, limit_stop
+				}
+				// 截断后未使用的部分退回
+				over := total - max
+				if over > int64(keep) {
+					over = int64(keep)
+				}
+				state.bytes.Add(-over)
+				keep -= int(over)
+			}
+		}
+	}
+	if keep == n {
+		return val, limit_ok
+	}
+	return truncate_bytes(val, keep), limit_cut
+}
+
+// 计入一个容器成员并检查其值，截断的值同时替换成员的原值
+func (tr *walker) limit_member(ctx context.Context, m *walkedMember, val *reflect.Value, path func() Path) limitAction {
+	if tr.limits == nil {
+		return limit_ok
+	}
+	if act := tr.count_node(ctx, path); act != limit_ok {
+		return act
+	}
+	if m.key.IsValid() {
+		// 截断的key可能与其他key冲突，丢弃整个键值对
+		if _, act := tr.limit_bytes(ctx, m.key, path); act == limit_cut {
+			return limit_drop
+		} else if act != limit_ok {
+			return act
+		}
+	}
+	nval, act := tr.limit_bytes(ctx, *val, path)
+	switch act {
+	case limit_cut:
+		*val, m.val = nval, nval
+	case limit_drop, limit_stop:
+		return act
+	}
+	return limit_ok
+}
+
+// 截断字符串或[]byte，字符串不截断在UTF-8字符中间
+func truncate_bytes(val reflect.Value, n int) reflect.Value {
+	if val.Kind() == reflect.Slice {
+		return val.Slice3(0, n, n)
+	}
+	s := val.String()
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	nv := reflect.New(val.Type()).Elem()
+	nv.SetString(s[:n])
+	return nv
+}
@@ -3,10 +3,16 @@ package reflect_walker
 import (
 	"errors"
 	"reflect"
+	"sync"
 )
 
 var (
-	ErrTypeAssertFailed = errors.New("type assertion failed")
+	ErrTypeAssertFailed  = errors.New("type assertion failed")
+	ErrOverflow          = errors.New("value overflow")
+	ErrPrecisionLoss     = errors.New("precision loss")
+	ErrParseFailed       = errors.New("parse failed")
+	ErrTypeMismatch      = errors.New("type mismatch")
+	ErrInsertUnsupported = errors.New("insert unsupported")
 )
 
 // TreeNode类型
@@ -53,6 +59,9 @@ type TreeVariable interface {
 	Float32() (float32, error)
 	Float64() (float64, error)
 	Bool() (bool, error)
+	Complex64() (complex64, error)
+	Complex128() (complex128, error)
+	Bytes() ([]byte, error) // []byte及json.RawMessage等以byte为元素的slice
 
 	// 类型断言，类型异常会panic
 	MustString() string
@@ -69,15 +78,37 @@ type TreeVariable interface {
 	MustFloat32() float32
 	MustFloat64() float64
 	MustBool() bool
+	MustComplex64() complex64
+	MustComplex128() complex128
+	MustBytes() []byte
 
-	// 修改值
-	Set(variable interface{})
+	// 宽松类型转换，跨Kind及具名类型转换，溢出、精度丢失、解析失败时返回错误
+	AsInt64() (int64, error)
+	AsUint64() (uint64, error)
+	AsFloat64() (float64, error)
+	AsString() (string, error)
+	AsBool() (bool, error)
+
+	// 修改值，按节点所在位置的声明类型检查并转换，类型不兼容时返回ErrTypeMismatch
+	Set(variable interface{}) error
 }
 
 type treeVariable struct {
 	node  TreeNode
 	t     reflect.Type
+	slot  reflect.Type // 节点所在位置的声明类型，如map的value类型、struct的字段类型，nil表示不检查
+	loose bool         // 是否允许修改为不兼容的类型，仅对重建的容器有效
 	value interface{}
+	rv    reflect.Value // 尚未装箱的值，首次读取时才转换为value，routine不读取时省去一次分配
+}
+
+// 读取值，按需装箱
+func (tv *treeVariable) get() interface{} {
+	if tv.rv.IsValid() {
+		tv.value = tv.rv.Interface()
+		tv.rv = reflect.Value{}
+	}
+	return tv.value
 }
 
 // 获取反射类型
@@ -85,9 +116,37 @@ func (tv *treeVariable) rtype() reflect.Type {
 	return tv.t
 }
 
-// 获取反射类型
+// 获取反射值，nil按声明类型取零值
 func (tv *treeVariable) rvalue() reflect.Value {
-	return reflect.ValueOf(tv.value)
+	if tv.rv.IsValid() && tv.rv.Kind() != reflect.Interface {
+		return tv.rv
+	}
+	return tv.reflect_value(tv.get())
+}
+
+func (tv *treeVariable) reflect_value(value interface{}) reflect.Value {
+	if value == nil {
+		if tv.slot != nil && is_nillable(tv.slot) {
+			return reflect.Zero(tv.slot)
+		}
+		return reflect.Zero(interfaceType)
+	}
+	return reflect.ValueOf(value)
+}
+
+// 按声明类型检查并转换value
+func (tv *treeVariable) fit(value interface{}) (interface{}, error) {
+	if tv.slot == nil {
+		return value, nil
+	}
+	nval, err := convert_value(reflect.ValueOf(value), tv.slot)
+	if err == nil {
+		return nval.Interface(), nil
+	}
+	if tv.loose {
+		return value, nil
+	}
+	return nil, err
 }
 
 // 获取原始类型名
@@ -101,177 +160,264 @@ func (tv *treeVariable) TypeKind() reflect.Kind {
 }
 
 func (tv *treeVariable) Interface() interface{} {
-	return tv.value
+	return tv.get()
 }
 
 func (tv *treeVariable) String() (string, error) {
-	if s, e := tv.value.(string); e {
+	if s, e := tv.get().(string); e {
 		return s, nil
 	}
 	return "", ErrTypeAssertFailed
 }
 
 func (tv *treeVariable) Int() (int, error) {
-	if u8, e := tv.value.(int); e {
+	if u8, e := tv.get().(int); e {
 		return u8, nil
 	}
 	return 0, ErrTypeAssertFailed
 }
 
 func (tv *treeVariable) Int8() (int8, error) {
-	if i8, e := tv.value.(int8); e {
+	if i8, e := tv.get().(int8); e {
 		return i8, nil
 	}
 	return 0, ErrTypeAssertFailed
 }
 
 func (tv *treeVariable) Int16() (int16, error) {
-	if i16, e := tv.value.(int16); e {
+	if i16, e := tv.get().(int16); e {
 		return i16, nil
 	}
 	return 0, ErrTypeAssertFailed
 }
 
 func (tv *treeVariable) Int32() (int32, error) {
-	if i32, e := tv.value.(int32); e {
+	if i32, e := tv.get().(int32); e {
 		return i32, nil
 	}
 	return 0, ErrTypeAssertFailed
 }
 
 func (tv *treeVariable) Int64() (int64, error) {
-	if i64, e := tv.value.(int64); e {
+	if i64, e := tv.get().(int64); e {
 		return i64, nil
 	}
 	return 0, ErrTypeAssertFailed
 }
 
 func (tv *treeVariable) Uint() (uint, error) {
-	if u8, e := tv.value.(uint); e {
+	if u8, e := tv.get().(uint); e {
 		return u8, nil
 	}
 	return 0, ErrTypeAssertFailed
 }
 
 func (tv *treeVariable) Uint8() (uint8, error) {
-	if u8, e := tv.value.(uint8); e {
+	if u8, e := tv.get().(uint8); e {
 		return u8, nil
 	}
 	return 0, ErrTypeAssertFailed
 }
 
 func (tv *treeVariable) Uint16() (uint16, error) {
-	if u16, e := tv.value.(uint16); e {
+	if u16, e := tv.get().(uint16); e {
 		return u16, nil
 	}
 	return 0, ErrTypeAssertFailed
 }
 
 func (tv *treeVariable) Uint32() (uint32, error) {
-	if u32, e := tv.value.(uint32); e {
+	if u32, e := tv.get().(uint32); e {
 		return u32, nil
 	}
 	return 0, ErrTypeAssertFailed
 }
 
 func (tv *treeVariable) Uint64() (uint64, error) {
-	if u64, e := tv.value.(uint64); e {
+	if u64, e := tv.get().(uint64); e {
 		return u64, nil
 	}
 	return 0, ErrTypeAssertFailed
 }
 
 func (tv *treeVariable) Float32() (float32, error) {
-	if f32, e := tv.value.(float32); e {
+	if f32, e := tv.get().(float32); e {
 		return f32, nil
 	}
 	return 0, ErrTypeAssertFailed
 }
 
 func (tv *treeVariable) Float64() (float64, error) {
-	if f64, e := tv.value.(float64); e {
+	if f64, e := tv.get().(float64); e {
 		return f64, nil
 	}
 	return 0, ErrTypeAssertFailed
 }
 
 func (tv *treeVariable) Bool() (bool, error) {
-	if b, e := tv.value.(bool); e {
+	if b, e := tv.get().(bool); e {
 		return b, nil
 	}
 	return false, ErrTypeAssertFailed
 }
 
+func (tv *treeVariable) Complex64() (complex64, error) {
+	if c64, e := tv.get().(complex64); e {
+		return c64, nil
+	}
+	return 0, ErrTypeAssertFailed
+}
+
+func (tv *treeVariable) Complex128() (complex128, error) {
+	if c128, e := tv.get().(complex128); e {
+		return c128, nil
+	}
+	return 0, ErrTypeAssertFailed
+}
+
+func (tv *treeVariable) Bytes() ([]byte, error) {
+	if b, e := tv.get().([]byte); e {
+		return b, nil
+	}
+	if v := reflect.ValueOf(tv.get()); v.IsValid() && is_bytes(v) {
+		return v.Bytes(), nil
+	}
+	return nil, ErrTypeAssertFailed
+}
+
 func (tv *treeVariable) MustString() string {
-	return tv.value.(string)
+	return tv.get().(string)
 }
 
 func (tv *treeVariable) MustInt() int {
-	return tv.value.(int)
+	return tv.get().(int)
 }
 
 func (tv *treeVariable) MustInt8() int8 {
-	return tv.value.(int8)
+	return tv.get().(int8)
 }
 
 func (tv *treeVariable) MustInt16() int16 {
-	return tv.value.(int16)
+	return tv.get().(int16)
 }
 
 func (tv *treeVariable) MustInt32() int32 {
-	return tv.value.(int32)
+	return tv.get().(int32)
 }
 
 func (tv *treeVariable) MustInt64() int64 {
-	return tv.value.(int64)
+	return tv.get().(int64)
 }
 
 func (tv *treeVariable) MustUint() uint {
-	return tv.value.(uint)
+	return tv.get().(uint)
 }
 
 func (tv *treeVariable) MustUint8() uint8 {
-	return tv.value.(uint8)
+	return tv.get().(uint8)
 }
 
 func (tv *treeVariable) MustUint16() uint16 {
-	return tv.value.(uint16)
+	return tv.get().(uint16)
 }
 
 func (tv *treeVariable) MustUint32() uint32 {
-	return tv.value.(uint32)
+	return tv.get().(uint32)
 }
 
 func (tv *treeVariable) MustUint64() uint64 {
-	return tv.value.(uint64)
+	return tv.get().(uint64)
 }
 
 func (tv *treeVariable) MustFloat32() float32 {
-	return tv.value.(float32)
+	return tv.get().(float32)
 }
 
 func (tv *treeVariable) MustFloat64() float64 {
-	return tv.value.(float64)
+	return tv.get().(float64)
 }
 
 func (tv *treeVariable) MustBool() bool {
-	return tv.value.(bool)
+	return tv.get().(bool)
+}
+
+func (tv *treeVariable) MustComplex64() complex64 {
+	return tv.get().(complex64)
+}
+
+func (tv *treeVariable) MustComplex128() complex128 {
+	return tv.get().(complex128)
 }
 
-func (tv *treeVariable) Set(value interface{}) {
+func (tv *treeVariable) MustBytes() []byte {
+	b, err := tv.Bytes()
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func (tv *treeVariable) AsInt64() (int64, error) {
+	return to_int64(reflect.ValueOf(tv.get()))
+}
+
+func (tv *treeVariable) AsUint64() (uint64, error) {
+	return to_uint64(reflect.ValueOf(tv.get()))
+}
+
+func (tv *treeVariable) AsFloat64() (float64, error) {
+	return to_float64(reflect.ValueOf(tv.get()))
+}
+
+func (tv *treeVariable) AsString() (string, error) {
+	return to_string(reflect.ValueOf(tv.get()))
+}
+
+func (tv *treeVariable) AsBool() (bool, error) {
+	return to_bool(reflect.ValueOf(tv.get()))
+}
+
+func (tv *treeVariable) Set(value interface{}) error {
 	n := tv.node
 	if n.getAction() == routine_delete {
-		return
+		return nil
+	}
+
+	value, err := tv.fit(value)
+	if err != nil {
+		return err
 	}
+
 	tv.node.setAction(routine_override)
 	tv.value = value
+	tv.rv = reflect.Value{}
+	if value != nil {
+		tv.t = reflect.TypeOf(value)
+	}
+	return nil
 }
 
+// 节点只在routine调用期间有效，遍历完所在容器后会被回收复用，routine返回后不要再持有节点
 type TreeNode interface {
 	Type() nType
 	Key() TreeVariable
 	Value() TreeVariable
 	Delete()
+	Defaulted() bool        // 值是否由WithDefaults填充
+	Path() Path             // 节点在整个输入中的路径
+	Depth() int             // 所在容器的嵌套深度，根容器的成员为0
+	Tag() reflect.StructTag // struct成员的标签，其他类型节点为空
+
+	// 向当前节点所在的map添加键值对，struct成员仅在struct输出为map时支持
+	// 新增的项不会再经过routine，在容器其余成员处理完后写入，会覆盖同名的key
+	// 每个成员的节点都可以插入，只需插入一次或容器为空时使用WithContainerRoutine
+	Insert(key, value interface{}) error
+	// 向当前节点的值添加键值对，值须为map（struct输出为map时也是map），立即写入，与值中已有的key同名时覆盖
+	// struct、slice成员中的容器需配合WithContainerNodes才有节点，根容器没有节点，这些场景使用WithContainerRoutine
+	InsertChild(key, value interface{}) error
+	// 在当前slice成员之前、之后插入元素，与Delete同时使用时相当于替换
+	InsertBefore(values ...interface{}) error
+	InsertAfter(values ...interface{}) error
 
 	// 内部接口
 	getAction() routine_action
@@ -283,6 +429,61 @@ type treeNode struct {
 	nKey   TreeVariable // 节点索引，当前仅map类型
 	nValue TreeVariable // 节点值
 	action routine_action
+
+	defaulted bool              // 值来自默认值
+	parent    *scope            // 所在容器，生成路径时才展开
+	elem      interface{}       // 在容器中的位置，NodeType_literal节点没有
+	index     int               // slice成员的下标，生成路径时才装箱
+	mapKey    reflect.Value     // map中原始的key，生成路径时才装箱
+	tag       reflect.StructTag // struct成员的标签
+
+	insertable bool          // 所在容器是否支持插入
+	inserts    []WalkPair    // 新增的键值对
+	before     []interface{} // 插入到当前成员之前的元素
+	after      []interface{} // 插入到当前成员之后的元素
+
+	box *nodeBox // 所在的复用对象
+}
+
+// 节点与其key、value一起分配，所在容器遍历完成后放回池中复用
+type nodeBox struct {
+	node  treeNode
+	key   treeVariable
+	value treeVariable
+}
+
+var nodePool = sync.Pool{
+	New: func() interface{} { return new(nodeBox) },
+}
+
+// 从池中取出节点，tn为节点的初始内容
+func new_node(tn treeNode) *treeNode {
+	b := nodePool.Get().(*nodeBox)
+	b.node = tn
+	b.node.box = b
+	return &b.node
+}
+
+func (tn *treeNode) set_key(tv treeVariable) {
+	tn.box.key = tv
+	tn.box.key.node = tn
+	tn.nKey = &tn.box.key
+}
+
+func (tn *treeNode) set_value(tv treeVariable) {
+	tn.box.value = tv
+	tn.box.value.node = tn
+	tn.nValue = &tn.box.value
+}
+
+// 回收节点，之后不能再访问
+func (tn *treeNode) release() {
+	if tn == nil || tn.box == nil {
+		return
+	}
+	b := tn.box
+	*b = nodeBox{}
+	nodePool.Put(b)
 }
 
 func (tn *treeNode) Type() nType {
@@ -301,6 +502,115 @@ func (tn *treeNode) Delete() {
 	tn.action = routine_delete
 }
 
+func (tn *treeNode) Defaulted() bool {
+	return tn.defaulted
+}
+
+func (tn *treeNode) Path() Path {
+	p := tn.parent.path(1)
+	switch tn.nType {
+	case NodeType_literal:
+	case NodeType_slice_member:
+		p = append(p, tn.index)
+	case NodeType_map_pair:
+		if tn.mapKey.IsValid() {
+			p = append(p, tn.mapKey.Interface())
+		} else {
+			p = append(p, tn.elem)
+		}
+	default:
+		p = append(p, tn.elem)
+	}
+	return p
+}
+
+func (tn *treeNode) Depth() int {
+	return tn.parent.levels()
+}
+
+func (tn *treeNode) Tag() reflect.StructTag {
+	return tn.tag
+}
+
+func (tn *treeNode) Insert(key, value interface{}) error {
+	if !tn.insertable || (tn.nType != NodeType_map_pair && tn.nType != NodeType_struct_member) {
+		return ErrInsertUnsupported
+	}
+
+	k, err := tn.nKey.(*treeVariable).fit(key)
+	if err != nil {
+		return err
+	}
+	v, err := tn.nValue.(*treeVariable).fit(value)
+	if err != nil {
+		return err
+	}
+	tn.inserts = append(tn.inserts, WalkPair{Key: k, Value: v})
+	return nil
+}
+
+func (tn *treeNode) InsertChild(key, value interface{}) error {
+	if tn.action == routine_delete {
+		return nil
+	}
+	tv := tn.nValue.(*treeVariable)
+	m := tv.rvalue()
+	if m.Kind() != reflect.Map {
+		return ErrInsertUnsupported
+	}
+
+	k, err := convert_value(reflect.ValueOf(key), m.Type().Key())
+	if err != nil {
+		return err
+	}
+	v, err := convert_value(reflect.ValueOf(value), m.Type().Elem())
+	if err != nil {
+		return err
+	}
+	if m.IsNil() {
+		if err := tv.Set(reflect.MakeMap(m.Type()).Interface()); err != nil {
+			return err
+		}
+		m = tv.rvalue()
+	}
+	m.SetMapIndex(k, v)
+	return nil
+}
+
+func (tn *treeNode) InsertBefore(values ...interface{}) error {
+	fitted, err := tn.fit_members(values)
+	if err != nil {
+		return err
+	}
+	tn.before = append(tn.before, fitted...)
+	return nil
+}
+
+func (tn *treeNode) InsertAfter(values ...interface{}) error {
+	fitted, err := tn.fit_members(values)
+	if err != nil {
+		return err
+	}
+	tn.after = append(tn.after, fitted...)
+	return nil
+}
+
+func (tn *treeNode) fit_members(values []interface{}) ([]interface{}, error) {
+	if !tn.insertable || tn.nType != NodeType_slice_member {
+		return nil, ErrInsertUnsupported
+	}
+
+	fitted := make([]interface{}, len(values))
+	for i, value := range values {
+		v, err := tn.nValue.(*treeVariable).fit(value)
+		if err != nil {
+			return nil, err
+		}
+		fitted[i] = v
+	}
+	return fitted, nil
+}
+
 func (tn *treeNode) getAction() routine_action {
 	return tn.action
 }
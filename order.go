@@ -0,0 +1,186 @@
+package reflect_walker
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+)
+
+// map key的比较函数，a排在b之前时返回true
+type KeyLess func(a, b interface{}) bool
+
+// 按key排序后遍历map，相同的数据每次遍历访问节点的顺序一致
+// 默认排序：数字按大小（NaN在最前），字符串按字典序，实现了encoding.TextMarshaler的key按序列化后的文本，
+// 不同种类的key按 nil、bool、数字、字符串、TextMarshaler、其他 的顺序排列，其他类型按fmt格式化后的文本比较
+// 传入less时使用自定义的比较函数
+// 比较结果相同的key（如多个NaN，或自定义比较中视为相同的key）再依次按默认排序与值排序，
+// 仍然相同的键值对按fmt格式化后一样，先后不影响输出
+func WithSortedMaps(less ...KeyLess) WalkOption {
+	return func(tw *walker) {
+		tw.sortedMaps = true
+		if len(less) > 0 {
+			tw.keyLess = less[0]
+		}
+	}
+}
+
+// 取出map的前limit个键值对，存放在两个slice中以避免逐个分配，开启排序时按key的顺序返回
+// 未排序时只复制前limit个，排序时需要先取出全部键值对，排序后再截取
+func (tr *walker) map_entries(m reflect.Value, limit int) (keys, vals reflect.Value) {
+	n := m.Len()
+	if !tr.sortedMaps && limit < n {
+		n = limit
+	}
+	keys = reflect.MakeSlice(reflect.SliceOf(m.Type().Key()), n, n)
+	vals = reflect.MakeSlice(reflect.SliceOf(m.Type().Elem()), n, n)
+	iter := m.MapRange()
+	for i := 0; i < n && iter.Next(); i++ {
+		keys.Index(i).SetIterKey(iter)
+		vals.Index(i).SetIterValue(iter)
+	}
+	if tr.sortedMaps && n > 1 {
+		keys, vals = tr.sort_entries(keys, vals)
+	}
+	if limit < n {
+		keys, vals = keys.Slice(0, limit), vals.Slice(0, limit)
+	}
+	return keys, vals
+}
+
+// 排序键值对，key相同时依次按默认排序与值排序，使结果与取出的先后无关
+func (tr *walker) sort_entries(keys, vals reflect.Value) (reflect.Value, reflect.Value) {
+	less := tr.keyLess
+	if less == nil {
+		less = default_key_less
+	}
+	n := keys.Len()
+	ikeys := make([]interface{}, n)
+	order := make([]int, n)
+	for i := range ikeys {
+		ikeys[i] = keys.Index(i).Interface()
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if less(ikeys[a], ikeys[b]) {
+			return true
+		}
+		if less(ikeys[b], ikeys[a]) {
+			return false
+		}
+		if ka, kb := keys.Index(a), keys.Index(b); value_less(ka, kb) || value_less(kb, ka) {
+			return value_less(ka, kb)
+		}
+		return value_less(vals.Index(a), vals.Index(b))
+	})
+
+	skeys, svals := reflect.MakeSlice(keys.Type(), n, n), reflect.MakeSlice(vals.Type(), n, n)
+	for i, o := range order {
+		skeys.Index(i).Set(keys.Index(o))
+		svals.Index(i).Set(vals.Index(o))
+	}
+	return skeys, svals
+}
+
+// key的种类，决定不同种类key之间的先后
+const (
+	keyRank_nil = iota
+	keyRank_bool
+	keyRank_number
+	keyRank_string
+	keyRank_text
+	keyRank_other
+)
+
+func key_rank(v reflect.Value) int {
+	if !v.IsValid() || (v.Kind() == reflect.Pointer && v.IsNil()) {
+		return keyRank_nil
+	}
+	if v.Type().Implements(textMarshalerType) {
+		return keyRank_text
+	}
+	switch {
+	case v.Kind() == reflect.Bool:
+		return keyRank_bool
+	case is_number_kind(v.Kind()):
+		return keyRank_number
+	case v.Kind() == reflect.String:
+		return keyRank_string
+	}
+	return keyRank_other
+}
+
+// 默认的key比较函数
+func default_key_less(a, b interface{}) bool {
+	return value_less(reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+// 按默认顺序比较两个值，不要求值可以调用Interface，如非公有字段中的map key
+func value_less(av, bv reflect.Value) bool {
+	ra, rb := key_rank(av), key_rank(bv)
+	if ra != rb {
+		return ra < rb
+	}
+
+	switch ra {
+	case keyRank_bool:
+		return !av.Bool() && bv.Bool()
+	case keyRank_number:
+		return number_less(av, bv)
+	case keyRank_string:
+		return av.String() < bv.String()
+	case keyRank_text:
+		return key_text(av) < key_text(bv)
+	case keyRank_nil:
+		return false
+	}
+	return fmt.Sprintf("%v", av) < fmt.Sprintf("%v", bv)
+}
+
+// TextMarshaler key的排序文本，序列化失败时按fmt格式化，同一个key的文本总是相同，保证排序的一致
+func key_text(v reflect.Value) string {
+	if v.CanInterface() {
+		if text, err := v.Interface().(encoding.TextMarshaler).MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// 数字比较，整数与浮点数之间也精确比较，NaN排在最前，保证是全序
+func number_less(a, b reflect.Value) bool {
+	ak, bk := a.Kind(), b.Kind()
+	switch {
+	case is_int_kind(ak) && is_int_kind(bk):
+		return a.Int() < b.Int()
+	case is_uint_kind(ak) && is_uint_kind(bk):
+		return a.Uint() < b.Uint()
+	case is_int_kind(ak) && is_uint_kind(bk):
+		return a.Int() < 0 || uint64(a.Int()) < b.Uint()
+	case is_uint_kind(ak) && is_int_kind(bk):
+		return b.Int() >= 0 && a.Uint() < uint64(b.Int())
+	}
+
+	an, bn := is_float_kind(ak) && math.IsNaN(a.Float()), is_float_kind(bk) && math.IsNaN(b.Float())
+	if an || bn {
+		return an && !bn
+	}
+	if is_float_kind(ak) && is_float_kind(bk) {
+		return a.Float() < b.Float()
+	}
+	// 整数转换为float64会丢失精度，如1<<53+1，用big.Float比较
+	return number_big(a).Cmp(number_big(b)) < 0
+}
+
+func number_big(v reflect.Value) *big.Float {
+	switch {
+	case is_int_kind(v.Kind()):
+		return new(big.Float).SetInt64(v.Int())
+	case is_uint_kind(v.Kind()):
+		return new(big.Float).SetUint64(v.Uint())
+	}
+	return big.NewFloat(v.Float())
+}
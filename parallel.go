@@ -0,0 +1,106 @@
+package reflect_walker
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// 并发遍历slice成员与map键值对，n为同时工作的goroutine数（含调用Walk的goroutine），n<=1时不并发
+// 整个遍历共享同一个工作池，嵌套容器在有空闲worker时同样并发遍历，重建后的容器与串行遍历的结果一致
+// 并发只发生在外层，嵌套超过maxForks层的容器串行遍历，深层嵌套的输入同样不会栈溢出
+//
+// 开启后routine会在多个goroutine中被同时调用，因此：
+//   - routine必须是并发安全的，闭包中共享的状态（计数、收集结果等）需要自行加锁
+//   - 传给routine的ctx与TreeNode只属于本次调用，可以放心读写
+//   - 多个成员引用同一个struct指针时，该struct会被并发原地修改，这种数据需要关闭并发或避免在routine中修改
+//
+// 遍历中的错误仍由WalkWithError返回，多个成员同时出错时返回其中之一
+// ctx被取消后不再遍历剩余成员，这些成员原样保留，WalkWithError返回ctx.Err()
+// routine中的panic会在调用Walk的goroutine中重新抛出
+func WithParallelism(n int) WalkOption {
+	return func(tw *walker) {
+		tw.parallelism = n
+	}
+}
+
+// 并发遍历的成员在worker中递归完成，超过此层数的容器改为串行遍历，避免深层嵌套的输入耗尽栈空间
+const maxForks = 32
+
+// 容器有n个成员时是否并发遍历
+func (tr *walker) can_parallel(ctx context.Context, sc *scope, n int) bool {
+	if tr.parallelism <= 1 || n < 2 || sc.forks >= maxForks {
+		return false
+	}
+	_, ok := ctx.Value(stateCtxKey).(*walkState)
+	return ok
+}
+
+// 并发地对容器的n个成员调用fn，被取消时剩余的成员不再调用
+func (tr *walker) each(ctx context.Context, n int, fn func(i int)) {
+	state := ctx.Value(stateCtxKey).(*walkState)
+
+	var (
+		next  int64 = -1
+		wg    sync.WaitGroup
+		once  sync.Once
+		fault interface{}
+	)
+	work := func() {
+		for {
+			i := int(atomic.AddInt64(&next, 1))
+			if i >= n || tr.cancelled(ctx) {
+				return
+			}
+			fn(i)
+		}
+	}
+
+	// 只占用空闲的worker，没有空闲时由当前goroutine独自完成，避免嵌套容器互相等待
+spawn:
+	for w := 1; w < n; w++ {
+		select {
+		case state.workers <- struct{}{}:
+		default:
+			break spawn
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-state.workers }()
+			defer func() {
+				if r := recover(); r != nil {
+					once.Do(func() { fault = r })
+				}
+			}()
+			work()
+		}()
+	}
+	work()
+	wg.Wait()
+
+	if fault != nil {
+		panic(fault)
+	}
+}
+
+// ctx已取消时记录错误，超出限制而停止时同样不再继续
+func (tr *walker) cancelled(ctx context.Context) bool {
+	if tr.limits != nil {
+		if state, ok := ctx.Value(stateCtxKey).(*walkState); ok && state.stopped.Load() {
+			return true
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		tr.report(ctx, err)
+		return true
+	}
+	return false
+}
+
+// 进入并发遍历的成员
+func fork_scope(sc *scope) *scope {
+	ns := *sc
+	ns.forks++
+	return &ns
+}
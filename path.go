@@ -0,0 +1,121 @@
+package reflect_walker
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// 节点路径，元素依次为struct的字段名、map的key或slice的下标(int)
+type Path []interface{}
+
+// 以"."连接各级，如db.replicas.0.host
+func (p Path) String() string {
+	segs := make([]string, len(p))
+	for i, e := range p {
+		segs[i] = fmt.Sprint(e)
+	}
+	return strings.Join(segs, ".")
+}
+
+// 按元素依次比较路径，元素按map key的默认顺序比较，前缀排在前面
+func path_less(a, b Path) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		av, bv := reflect.ValueOf(a[i]), reflect.ValueOf(b[i])
+		if value_less(av, bv) {
+			return true
+		}
+		if value_less(bv, av) {
+			return false
+		}
+	}
+	return len(a) < len(b)
+}
+
+// 当前所在容器的遍历状态，随遍历逐层向下传递
+// 不放在context中，嵌套很深时context链会很长，每次查找都要从头走到尾
+// 修改标记时复制一份，复制出的scope与原scope路径相同
+type scope struct {
+	parent  *scope
+	elem    interface{} // 在上一级容器中的位置
+	hasElem bool
+
+	depth     int            // 嵌套深度，仅在限制深度时计数
+	forks     int            // 经过的并发遍历层数
+	strict    bool           // 所在位置的声明类型不是interface{}
+	defaulted bool           // 值来自默认值
+	inlined   bool           // 展开到上一级的嵌入struct，与上一级路径相同
+	allocated []reflect.Type // 当前路径上因默认值分配过的类型
+}
+
+// 遍历的起点
+func root_scope() *scope {
+	return &scope{depth: -1}
+}
+
+// 当前所在容器的路径，extra为预留的容量
+func (sc *scope) path(extra int) Path {
+	n := sc.levels()
+	p := make(Path, n, n+extra)
+	for s := sc; s != nil; s = s.parent {
+		if s.hasElem {
+			n--
+			p[n] = s.elem
+		}
+	}
+	return p
+}
+
+// 当前所在容器的层数，根容器为0
+func (sc *scope) levels() int {
+	n := 0
+	for s := sc; s != nil; s = s.parent {
+		if s.hasElem {
+			n++
+		}
+	}
+	return n
+}
+
+// 进入子容器
+func enter_path(sc *scope, elem interface{}) *scope {
+	child := *sc
+	child.parent, child.elem, child.hasElem = sc, elem, true
+	child.inlined = false
+	return &child
+}
+
+// 进入展开到上一级的嵌入struct
+func inline_scope(sc *scope) *scope {
+	ns := *sc
+	ns.inlined = true
+	return &ns
+}
+
+// 只有routine、key冲突、超出限制的错误与intercept的使用者（ToGeneric、Table）会用到路径，都不需要时不记录，省去每个容器的分配
+func (tr *walker) tracks_path() bool {
+	return tr.emits_nodes() || len(tr.containers) > 0 || tr.keyTransform != nil || tr.limits != nil || tr.intercept != nil
+}
+
+func (tr *walker) enter_path(sc *scope, elem interface{}) *scope {
+	if !tr.tracks_path() {
+		return sc
+	}
+	return enter_path(sc, elem)
+}
+
+// 进入slice成员，下标只在需要时装箱
+func (tr *walker) enter_index(sc *scope, i int) *scope {
+	if !tr.tracks_path() {
+		return sc
+	}
+	return enter_path(sc, i)
+}
+
+// 进入map成员，key只在需要时装箱
+func (tr *walker) enter_key(sc *scope, key reflect.Value) *scope {
+	if !tr.tracks_path() {
+		return sc
+	}
+	return enter_path(sc, key.Interface())
+}
@@ -0,0 +1,107 @@
+package reflect_walker
+
+import (
+	"reflect"
+	"sync"
+)
+
+// 按类型缓存的遍历计划，避免每次遍历重复做反射查询
+// 与walker选项无关的struct字段信息在包级别缓存，每次请求新建walker时同样可以复用
+// 与选项相关的判断（是否为叶子类型等）缓存在walker上
+type typePlan struct {
+	kind     reflect.Kind
+	leaf     bool        // 通过WithLeafTypes等选项视为叶子
+	literal  bool        // 作为字面量处理，不再深入遍历
+	walkable bool        // 实现了Walkable，或其指针实现了Walkable
+	fields   []fieldPlan // struct的字段
+}
+
+type fieldPlan struct {
+	index     int
+	field     reflect.StructField
+	exported  bool
+	name      string // 标签名，没有标签时为字段名
+	skip      bool   // 标签为"-"
+	inline    bool   // 匿名嵌入且没有标签名，或带inline选项的struct，输出为map时展开到上一级
+	omitEmpty bool   // 带omitempty选项，输出为map时零值不输出
+
+	// 预先装箱，避免每次遍历分配
+	elem    interface{}   // 字段名，用于路径
+	key     reflect.Value // 字段名
+	jsonKey reflect.Value // 标签名
+}
+
+// 字段计划按类型与标签名缓存
+type planKey struct {
+	t       reflect.Type
+	tagName string
+}
+
+var (
+	walkableType = reflect.TypeOf((*Walkable)(nil)).Elem()
+	fieldPlans   sync.Map // planKey -> []fieldPlan
+)
+
+func (tr *walker) plan(t reflect.Type) *typePlan {
+	if cached, ok := tr.plans.Load(t); ok {
+		return cached.(*typePlan)
+	}
+
+	p := &typePlan{
+		kind:     t.Kind(),
+		leaf:     tr.is_leaf_type(t),
+		walkable: t.Implements(walkableType) || reflect.PointerTo(t).Implements(walkableType),
+	}
+	switch p.kind {
+	case reflect.Slice:
+		// []byte、json.RawMessage等视为字面量
+		p.literal = p.leaf || t.Elem().Kind() == reflect.Uint8
+	case reflect.Array, reflect.Map, reflect.Struct, reflect.Pointer:
+		p.literal = p.leaf
+	default:
+		p.literal = true
+	}
+	if p.kind == reflect.Struct {
+		p.fields = struct_plan(t, tr.tag_name())
+	}
+
+	cached, _ := tr.plans.LoadOrStore(t, p)
+	return cached.(*typePlan)
+}
+
+// 字段名使用的标签，默认为json
+func (tr *walker) tag_name() string {
+	if tr.tagName == "" {
+		return "json"
+	}
+	return tr.tagName
+}
+
+// struct的字段计划，遍历、ToMap、GoSyntax共用
+func struct_plan(t reflect.Type, tagName string) []fieldPlan {
+	pk := planKey{t: t, tagName: tagName}
+	if cached, ok := fieldPlans.Load(pk); ok {
+		return cached.([]fieldPlan)
+	}
+
+	fields := make([]fieldPlan, t.NumField())
+	for i := range fields {
+		sf := t.Field(i)
+		name, opts, skip := parse_field_tag(sf, tagName)
+		fields[i] = fieldPlan{
+			index:     i,
+			field:     sf,
+			exported:  sf.IsExported(),
+			name:      name,
+			skip:      skip,
+			inline:    (sf.Anonymous && name == sf.Name || has_option(opts, "inline")) && indirect_type(sf.Type).Kind() == reflect.Struct,
+			omitEmpty: has_option(opts, "omitempty"),
+			elem:      sf.Name,
+			key:       reflect.ValueOf(sf.Name),
+			jsonKey:   reflect.ValueOf(name),
+		}
+	}
+
+	cached, _ := fieldPlans.LoadOrStore(pk, fields)
+	return cached.([]fieldPlan)
+}
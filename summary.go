@@ -0,0 +1,161 @@
+package reflect_walker
+
+import (
+	"context"
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"unicode/utf8"
+)
+
+type SummaryOption func(s *summarizer)
+
+type summarizer struct {
+	maxChars   int // 字符串保留的字符数
+	maxEntries int // slice、map保留的成员数
+	maxDepth   int // 展开的层数
+}
+
+// 字符串最多保留n个字符，默认256
+func WithSummaryChars(n int) SummaryOption {
+	return func(s *summarizer) {
+		s.maxChars = n
+	}
+}
+
+// slice、map最多保留n个成员，默认32
+func WithSummaryEntries(n int) SummaryOption {
+	return func(s *summarizer) {
+		s.maxEntries = n
+	}
+}
+
+// 最多展开n层容器（含最外层），默认8，n<=0时不限制，此时输入中的环会导致无限展开
+func WithSummaryDepth(n int) SummaryOption {
+	return func(s *summarizer) {
+		s.maxDepth = n
+	}
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// 生成适合写入日志的摘要，结果可以直接json.Marshal，原值不会被修改
+//   - 超长的字符串截断并追加"…(+123 chars)"，超长的[]byte替换为"<123 bytes>"
+//   - slice只保留前若干个成员并追加"…(+5 entries)"，map按key排序保留前若干个键值对，并以"…"为key记录省略的数量
+//   - 超过层数的容器替换为"<max depth>"
+//   - struct转换为map，error转换为Error()的结果，实现了encoding.TextMarshaler或json.Marshaler的值原样保留
+//   - func、chan、unsafe.Pointer替换为"<func>"、"<chan>"、"<unsafe.Pointer>"
+//   - 复数、NaN、±Inf转换为字符串，map的key转换为字符串
+func Summarize(v interface{}, opts ...SummaryOption) interface{} {
+	s := &summarizer{maxChars: 256, maxEntries: 32, maxDepth: 8}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	depth := NoDepthLimit
+	if s.maxDepth > 0 {
+		depth = s.maxDepth - 1
+	}
+
+	tw := NewTreeWalker(
+		WithStructAsMap(),
+		WithJsonableMap(),
+		WithSortedMaps(),
+		WithTextMarshalerLeaves(),
+		WithJsonMarshalerLeaves(),
+		WithMaxDepth(depth),
+		WithLimits(Limits{MaxElements: s.maxEntries, Truncate: true}),
+		WithRoutine(s.summarize),
+	).(*walker)
+	tw.leafIfaces = append(tw.leafIfaces, errorType)
+	tw.copying = true
+	tw.elided = func(n int) string { return fmt.Sprintf("…(+%d entries)", n) }
+	tw.tooDeep = "<max depth>"
+	tw.opaque = func(in interface{}) interface{} {
+		out, _ := s.placeholder(reflect.ValueOf(in))
+		return out
+	}
+	return tw.Walk(context.Background(), v)
+}
+
+func (s *summarizer) summarize(ctx context.Context, node TreeNode) {
+	if node.Type() == NodeType_map_pair {
+		// 结果中的map都是map[string]...，key需要转换为string
+		if key := reflect.ValueOf(node.Key().Interface()); !key.IsValid() || key.Type() != stringType {
+			node.Key().Set(summary_key(key))
+		}
+	}
+
+	val := reflect.ValueOf(node.Value().Interface())
+	if out, ok := s.placeholder(val); ok {
+		node.Value().Set(out)
+	}
+}
+
+var stringType = reflect.TypeOf("")
+
+// 不能直接序列化或需要截断的值，返回替换后的值
+func (s *summarizer) placeholder(val reflect.Value) (interface{}, bool) {
+	if !val.IsValid() {
+		return nil, false
+	}
+
+	if val.Type().Implements(errorType) {
+		if is_nillable(val.Type()) && val.IsNil() {
+			return nil, false
+		}
+		return s.truncate(val.Interface().(error).Error()), true
+	}
+
+	switch val.Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return "<" + val.Kind().String() + ">", true
+	case reflect.Complex64, reflect.Complex128:
+		return fmt.Sprint(val.Complex()), true
+	case reflect.Float32, reflect.Float64:
+		if f := val.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Sprint(f), true
+		}
+	case reflect.String:
+		if str := val.String(); utf8.RuneCountInString(str) > s.maxChars {
+			return s.truncate(str), true
+		}
+	case reflect.Slice:
+		if val.Type().Elem().Kind() == reflect.Uint8 && val.Len() > s.maxChars {
+			return fmt.Sprintf("<%d bytes>", val.Len()), true
+		}
+	}
+	return nil, false
+}
+
+// 截断字符串，保留前maxChars个字符
+func (s *summarizer) truncate(str string) string {
+	n := utf8.RuneCountInString(str)
+	if n <= s.maxChars {
+		return str
+	}
+
+	cut := 0
+	for i := 0; i < s.maxChars; i++ {
+		_, size := utf8.DecodeRuneInString(str[cut:])
+		cut += size
+	}
+	return fmt.Sprintf("%s…(+%d chars)", str[:cut], n-s.maxChars)
+}
+
+// map的key转换为字符串，优先使用encoding.TextMarshaler
+func summary_key(key reflect.Value) string {
+	if !key.IsValid() {
+		return fmt.Sprint(nil)
+	}
+	if tm, ok := key.Interface().(encoding.TextMarshaler); ok {
+		if b, err := tm.MarshalText(); err == nil {
+			return string(b)
+		}
+	}
+	if key.Kind() == reflect.String {
+		return key.String()
+	}
+	return fmt.Sprint(key.Interface())
+}
@@ -0,0 +1,120 @@
+package reflect_walker
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func Test_Summarize(t *testing.T) {
+	type inner struct {
+		Next *inner `json:"next"`
+		Tag  string `json:"tag"`
+	}
+	type record struct {
+		Name    string                 `json:"name"`
+		Handler func()                 `json:"handler"`
+		Events  chan int               `json:"events"`
+		Raw     unsafe.Pointer         `json:"raw"`
+		Err     error                  `json:"err"`
+		When    time.Time              `json:"when"`
+		Scores  []float64              `json:"scores"`
+		Counts  map[int]int            `json:"counts"`
+		Nested  *inner                 `json:"nested"`
+		Extra   map[string]interface{} `json:"extra"`
+		private string
+	}
+
+	when := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	input := &record{
+		Name:    strings.Repeat("é", 10),
+		Handler: func() {},
+		Events:  make(chan int),
+		Err:     errors.New("boom"),
+		When:    when,
+		Scores:  []float64{1, math.NaN(), math.Inf(1), 4, 5},
+		Counts:  map[int]int{3: 30, 1: 10, 2: 20, 4: 40},
+		Nested:  &inner{Tag: "a", Next: &inner{Tag: "b", Next: &inner{Tag: "c"}}},
+		Extra:   map[string]interface{}{"bytes": []byte(strings.Repeat("x", 20)), "c": complex(1, 2)},
+	}
+
+	ch, fn := make(chan int), func() {}
+
+	testCases := []struct {
+		name   string
+		input  interface{}
+		opts   []SummaryOption
+		expect interface{}
+	}{
+		{
+			"struct",
+			input,
+			[]SummaryOption{WithSummaryChars(4), WithSummaryEntries(3), WithSummaryDepth(3)},
+			map[string]interface{}{
+				"name":    "éééé…(+6 chars)",
+				"handler": "<func>",
+				"events":  "<chan>",
+				"raw":     "<unsafe.Pointer>",
+				"err":     "boom",
+				"when":    when,
+				"scores":  []interface{}{1.0, "NaN", "+Inf", "…(+2 entries)"},
+				"counts":  map[string]interface{}{"1": 10, "2": 20, "3": 30, "…": "…(+1 entries)"},
+				"nested": map[string]interface{}{
+					"tag":  "a",
+					"next": map[string]interface{}{"tag": "b", "next": "<max depth>"},
+				},
+				"extra": map[string]interface{}{"bytes": "<20 bytes>", "c": "(1+2i)"},
+			},
+		},
+		{"字符串", strings.Repeat("a", 300), nil, strings.Repeat("a", 256) + "…(+44 chars)"},
+		{"func", func() {}, nil, "<func>"},
+		{"nil", nil, nil, nil},
+		{"指向chan的指针", &ch, nil, "<chan>"},
+		{
+			"成员是指向chan、func的指针",
+			struct {
+				C *chan int
+				F *func()
+			}{C: &ch, F: &fn},
+			nil,
+			map[string]interface{}{"C": "<chan>", "F": "<func>"},
+		},
+		{"slice成员是指向chan的指针", []*chan int{&ch}, nil, []interface{}{"<chan>"}},
+		{"map值是指向func的指针", map[string]interface{}{"f": &fn}, nil, map[string]interface{}{"f": "<func>"}},
+		{"error", errors.New("failed"), nil, "failed"},
+		{
+			"数组",
+			struct {
+				Scores [3]float64
+				Chans  [1]chan int
+				Names  [2]string
+			}{Scores: [3]float64{math.NaN(), 1, 2}, Chans: [1]chan int{make(chan int)}, Names: [2]string{"abcdef", "x"}},
+			[]SummaryOption{WithSummaryChars(3), WithSummaryEntries(2)},
+			map[string]interface{}{
+				"Scores": []interface{}{"NaN", 1.0, "…(+1 entries)"},
+				"Chans":  []interface{}{"<chan>"},
+				"Names":  []interface{}{"abc…(+3 chars)", "x"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		got := Summarize(tc.input, tc.opts...)
+		if !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("miss match: \n\tinput: %s\n\texpect:%#v\n\tgot:   %#v", tc.name, tc.expect, got)
+		}
+		if _, err := json.Marshal(got); err != nil {
+			t.Errorf("miss match: \n\tinput: %s\n\texpect:marshalable\n\tgot:   %v", tc.name, err)
+		}
+	}
+
+	// 原值不被修改
+	if utf8Len := len([]rune(input.Name)); utf8Len != 10 || len(input.Scores) != 5 || input.Nested.Next.Next.Tag != "c" {
+		t.Errorf("input modified: %+v", input)
+	}
+}
@@ -0,0 +1,414 @@
+package reflect_walker
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+type TableOption func(tb *tabler)
+
+// 列名使用的标签，默认为json
+func WithTableTagName(name string) TableOption {
+	return func(tb *tabler) {
+		tb.tagName = name
+	}
+}
+
+// 列名中各级之间的分隔符，默认为"."
+func WithTableSeparator(sep string) TableOption {
+	return func(tb *tabler) {
+		tb.sep = sep
+	}
+}
+
+// 只输出指定的列，按给定的顺序，行中没有的列为空
+func WithTableColumns(cols ...string) TableOption {
+	return func(tb *tabler) {
+		tb.columns = cols
+	}
+}
+
+// 表头中列的显示名，未指定的列使用列名
+func WithTableHeaders(names map[string]string) TableOption {
+	return func(tb *tabler) {
+		tb.headers = names
+	}
+}
+
+// WriteCSV不输出表头
+func WithoutTableHeader() TableOption {
+	return func(tb *tabler) {
+		tb.noHeader = true
+	}
+}
+
+type tabler struct {
+	tagName  string
+	sep      string
+	columns  []string
+	headers  map[string]string
+	noHeader bool
+
+	tr        *walker
+	ctx       context.Context
+	guard     cycleGuard              // 当前路径上的指针、map、slice，用于检测环
+	root      *tableColumn            // 列按路径组成的树
+	names     map[string]*tableColumn // 叶子列的列名，不同路径拼接后相同时冲突
+	levels    []tableLevel            // 正在遍历的容器
+	cells     map[string]string       // 当前行
+	declaring map[reflect.Type]bool   // 正在按类型登记的struct，递归的类型只登记一层
+	zero      reflect.Type            // 下一个nil指针替换为该struct的零值，用于登记列
+}
+
+// 正在遍历的容器
+type tableLevel struct {
+	sc       *scope
+	col      *tableColumn
+	kind     int
+	fields   []fieldPlan     // struct的字段计划
+	next     int             // 下一个待匹配的字段
+	own      map[string]bool // struct中已登记的列，外层字段优先于展开的嵌入字段，与展开的嵌入struct共用
+	quiet    bool            // 按类型登记的列，不输出单元格
+	declares reflect.Type    // 按类型登记的struct
+}
+
+// 将rows（slice或array，元素通常是struct）转换为表格，每个元素一行
+// 列名是展开后的字段路径，如db.replicas.0.host，字段的取舍与ToMap相同：名字取自标签（默认json），
+// 匿名嵌入的结构体字段展开到上一级，带omitempty的零值字段不输出
+//   - struct的列按字段定义顺序，展开的嵌入字段在外层字段之后，nil指针对应的列来自类型，单元格为空
+//   - map的列是所有行中key的并集，按key排序；slice的列是所有行中下标的并集
+//   - 实现了encoding.TextMarshaler或json.Marshaler的值作为一个单元格，如time.Time
+//
+// 同一路径在不同行中既是叶子又是容器时返回ErrTypeMismatch，不同路径拼接出相同的列名时返回ErrKeyCollision
+func Table(rows interface{}, opts ...TableOption) (header []string, records [][]string, err error) {
+	return new_tabler(opts).table(rows)
+}
+
+// 将rows按Table转换后写入w，写完后Flush，可事先设置w.Comma等
+func WriteCSV(w *csv.Writer, rows interface{}, opts ...TableOption) error {
+	tb := new_tabler(opts)
+	header, records, err := tb.table(rows)
+	if err != nil {
+		return err
+	}
+	if !tb.noHeader {
+		if err := w.Write(header); err != nil {
+			return err
+		}
+	}
+	return w.WriteAll(records)
+}
+
+func new_tabler(opts []TableOption) *tabler {
+	tb := &tabler{tagName: "json", sep: ".", root: &tableColumn{}, names: map[string]*tableColumn{}, declaring: map[reflect.Type]bool{}}
+	for _, option := range opts {
+		option(tb)
+	}
+	return tb
+}
+
+func (tb *tabler) table(rows interface{}) (header []string, records [][]string, err error) {
+	rv := indirect_value(reflect.ValueOf(rows))
+	if !rv.IsValid() || rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, nil, fmt.Errorf("%w: rows must be a slice, got %T", ErrTypeMismatch, rows)
+	}
+
+	// 每一行在遍历引擎上按struct输出为map的方式遍历，每个值都交给tabler登记列与单元格
+	tw := &walker{maxDepth: NoDepthLimit, copying: true, structAsMap: true, tagName: tb.tagName, intercept: tb.intercept}
+	ctx, state := tw.begin(context.Background())
+	tb.tr, tb.ctx, tb.guard = tw, ctx, cycleGuard{tr: tw, ctx: ctx}
+
+	cells := make([]map[string]string, rv.Len())
+	for i := range cells {
+		cells[i] = map[string]string{}
+		tb.cells = cells[i]
+		e := &engine{tr: tw, ctx: ctx, obs: tb}
+		e.start(enter_path(root_scope(), i), rv.Index(i).Interface())
+		// 没有routine，不会生成节点
+		e.next()
+		if state.err != nil {
+			return nil, nil, state.err
+		}
+	}
+
+	columns := tb.columns
+	if columns == nil {
+		columns = tb.root.leaves(nil)
+	}
+
+	header = make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col
+		if name, ok := tb.headers[col]; ok {
+			header[i] = name
+		}
+	}
+	records = make([][]string, len(cells))
+	for i, row := range cells {
+		records[i] = make([]string, len(columns))
+		for j, col := range columns {
+			records[i][j] = row[col]
+		}
+	}
+	return header, records, nil
+}
+
+const (
+	column_unknown = iota // 只在nil的map、slice中出现过，不输出
+	column_leaf
+	column_struct
+	column_map
+	column_index
+)
+
+// 列按路径组成的树，叶子是输出的列
+type tableColumn struct {
+	name     string // 完整的列名
+	kind     int
+	children []*tableColumn
+	byKey    map[string]*tableColumn
+	elem     interface{} // map中原始的key或slice下标，用于排序
+}
+
+func (c *tableColumn) child(sep, key string) *tableColumn {
+	if cc, ok := c.byKey[key]; ok {
+		return cc
+	}
+	if c.byKey == nil {
+		c.byKey = map[string]*tableColumn{}
+	}
+
+	cc := &tableColumn{name: key}
+	if c.name != "" {
+		cc.name = c.name + sep + key
+	}
+	c.byKey[key] = cc
+	c.children = append(c.children, cc)
+	return cc
+}
+
+func (c *tableColumn) set_kind(path Path, kind int) error {
+	if c.kind != column_unknown && c.kind != kind {
+		return &PathError{Path: path, Err: fmt.Errorf("%w: column %q is both a value and a container", ErrTypeMismatch, c.name)}
+	}
+	c.kind = kind
+	return nil
+}
+
+// 按深度优先列出叶子列，struct按字段顺序，map按原始key排序，slice按下标排序
+func (c *tableColumn) leaves(out []string) []string {
+	switch c.kind {
+	case column_leaf:
+		return append(out, c.name)
+	case column_map, column_index:
+		sort.SliceStable(c.children, func(i, j int) bool {
+			return value_less(reflect.ValueOf(c.children[i].elem), reflect.ValueOf(c.children[j].elem))
+		})
+	}
+	for _, cc := range c.children {
+		out = cc.leaves(out)
+	}
+	return out
+}
+
+func (tb *tabler) enter(sc *scope, in interface{}) bool {
+	col, own, ok := tb.column(sc)
+	if !ok || in == nil {
+		return false
+	}
+	quiet := len(tb.levels) > 0 && tb.levels[len(tb.levels)-1].quiet
+
+	v := reflect.ValueOf(in)
+	for (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && !is_table_leaf(v.Type()) {
+		if v.IsNil() {
+			if v.Kind() == reflect.Pointer {
+				// 可选的struct，列依然来自类型
+				return tb.declare(sc, col, own, v.Type().Elem())
+			}
+			return false
+		}
+		v = v.Elem()
+	}
+
+	t := v.Type()
+	level := tableLevel{sc: sc, col: col, quiet: quiet}
+	switch {
+	case is_table_leaf(t):
+		tb.leaf(sc, col, v, quiet)
+		return false
+	case tb.tr.plan(reflect.TypeOf(in)).walkable || tb.tr.plan(t).walkable:
+		level.kind = column_map
+	case t.Kind() == reflect.Struct:
+		if err := col.set_kind(sc.path(0), column_struct); err != nil {
+			tb.tr.report(tb.ctx, err)
+			return false
+		}
+		level.kind, level.fields, level.own = column_struct, tb.tr.plan(t).fields, own
+	case t.Kind() == reflect.Map:
+		level.kind = column_map
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		level.kind = column_index
+	default:
+		tb.leaf(sc, col, v, quiet)
+		return false
+	}
+	return tb.push(sc, in, level)
+}
+
+// 容器的成员都遍历完成
+func (tb *tabler) leave() {
+	level := tb.levels[len(tb.levels)-1]
+	tb.levels = tb.levels[:len(tb.levels)-1]
+	tb.guard.leave()
+	if level.declares != nil {
+		delete(tb.declaring, level.declares)
+	}
+}
+
+func (tb *tabler) too_deep(sc *scope, in interface{}) {}
+
+func (tb *tabler) push(sc *scope, in interface{}, level tableLevel) bool {
+	if !tb.guard.enter(sc, in) {
+		return false
+	}
+	if level.kind == column_struct && level.own == nil {
+		level.own = map[string]bool{}
+	}
+	tb.levels = append(tb.levels, level)
+	return true
+}
+
+// sc处的值对应的列，以及所在struct已登记的列，被外层字段遮蔽的嵌入字段返回false
+func (tb *tabler) column(sc *scope) (*tableColumn, map[string]bool, bool) {
+	if len(tb.levels) == 0 {
+		return tb.root, nil, true
+	}
+	top := &tb.levels[len(tb.levels)-1]
+	if sc.inlined {
+		// 展开到上一级的嵌入struct
+		return top.col, top.own, true
+	}
+
+	var (
+		cc  *tableColumn
+		err error
+	)
+	switch top.kind {
+	case column_struct:
+		name := top.field(sc.elem)
+		if top.own[name] {
+			return nil, nil, false
+		}
+		top.own[name] = true
+		cc = top.col.child(tb.sep, name)
+	case column_map:
+		key, kerr := to_string(reflect.ValueOf(sc.elem))
+		if kerr != nil {
+			key = fmt.Sprint(sc.elem)
+		}
+		err = top.col.set_kind(sc.path(0), column_map)
+		cc = top.col.child(tb.sep, key)
+		cc.elem = sc.elem
+	default:
+		err = top.col.set_kind(sc.path(0), column_index)
+		cc = top.col.child(tb.sep, fmt.Sprint(sc.elem))
+		cc.elem = sc.elem
+	}
+	if err != nil {
+		tb.tr.report(tb.ctx, err)
+		return nil, nil, false
+	}
+	return cc, nil, true
+}
+
+// struct成员elem的列名，字段按顺序遍历，从上一个匹配的字段之后查找
+func (l *tableLevel) field(elem interface{}) string {
+	for i := l.next; i < len(l.fields); i++ {
+		if l.fields[i].elem == elem {
+			l.next = i + 1
+			return l.fields[i].name
+		}
+	}
+	return fmt.Sprint(elem)
+}
+
+// 登记叶子列，不同路径拼接出相同的列名时返回ErrKeyCollision，如"a.b"与a下的"b"
+func (tb *tabler) leaf(sc *scope, col *tableColumn, v reflect.Value, quiet bool) {
+	if err := col.set_kind(sc.path(0), column_leaf); err != nil {
+		tb.tr.report(tb.ctx, err)
+		return
+	}
+	if other, ok := tb.names[col.name]; ok && other != col {
+		tb.tr.report(tb.ctx, &PathError{Path: sc.path(0), Err: fmt.Errorf("%w: column %q", ErrKeyCollision, col.name)})
+		return
+	}
+	tb.names[col.name] = col
+	if v.IsValid() && !quiet && (!is_nillable(v.Type()) || !v.IsNil()) {
+		tb.cells[col.name] = format_cell(v)
+	}
+}
+
+// 按类型登记nil指针指向的值的列，struct的零值交给引擎遍历，map与slice的列只来自数据
+func (tb *tabler) declare(sc *scope, col *tableColumn, own map[string]bool, t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer && !is_table_leaf(t) {
+		t = t.Elem()
+	}
+
+	switch {
+	case is_table_leaf(t):
+	case t.Kind() == reflect.Struct:
+		if tb.declaring[t] {
+			return false
+		}
+		if err := col.set_kind(sc.path(0), column_struct); err != nil {
+			tb.tr.report(tb.ctx, err)
+			return false
+		}
+		if !tb.push(sc, nil, tableLevel{sc: sc, col: col, kind: column_struct, fields: tb.tr.plan(t).fields, own: own, quiet: true, declares: t}) {
+			return false
+		}
+		tb.declaring[t] = true
+		tb.zero = t
+		return true
+	case t.Kind() == reflect.Map || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Interface:
+		return false
+	}
+	tb.leaf(sc, col, reflect.Value{}, true)
+	return false
+}
+
+// 遍历每个值之前调用，declare登记的nil指针替换为struct的零值
+func (tb *tabler) intercept(in interface{}) (interface{}, bool) {
+	if tb.zero != nil {
+		if v := reflect.ValueOf(in); v.Kind() == reflect.Pointer && v.IsNil() {
+			z := tb.zero
+			tb.zero = nil
+			return reflect.Zero(z).Interface(), false
+		}
+	}
+	return in, false
+}
+
+// 作为单元格的类型：实现了TextMarshaler或json.Marshaler，以及[]byte
+func is_table_leaf(t reflect.Type) bool {
+	return is_marshaler(t) || t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+func format_cell(v reflect.Value) string {
+	if s, err := to_string(v); err == nil {
+		return s
+	}
+	if !v.CanInterface() {
+		return fmt.Sprint(v)
+	}
+	if m, ok := v.Interface().(json.Marshaler); ok {
+		if b, err := m.MarshalJSON(); err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,134 @@
+package reflect_walker
+
+import (
+	"encoding/csv"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Table(t *testing.T) {
+	type address struct {
+		City string `json:"city"`
+		Zip  string `json:"zip"`
+	}
+	type Audit struct {
+		Created time.Time `json:"created"`
+	}
+	type record struct {
+		Audit
+		ID      int               `json:"id"`
+		Name    string            `json:"name"`
+		Home    *address          `json:"home"`
+		Labels  map[string]string `json:"labels"`
+		Tags    []string          `json:"tags"`
+		Score   *float64          `json:"score"`
+		Ignored string            `json:"-"`
+		secret  string
+	}
+	type node struct {
+		Name string
+		Next *node
+	}
+	type Base struct {
+		Name string
+		Kind string
+	}
+	type outer struct {
+		Base
+		Name string
+	}
+
+	when := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	score := 9.5
+	rows := []record{
+		{Audit: Audit{when}, ID: 1, Name: "a", Home: &address{City: "x", Zip: "1"}, Labels: map[string]string{"env": "prod"}, Tags: []string{"t1"}, Score: &score, Ignored: "i", secret: "s"},
+		{ID: 2, Name: "b,c", Labels: map[string]string{"team": "core", "env": "dev"}, Tags: []string{"t2", "t3"}},
+	}
+	loop := &node{Name: "a"}
+	loop.Next = loop
+	selfMap := map[string]interface{}{"a": 1}
+	selfMap["self"] = selfMap
+
+	testCases := []struct {
+		name    string
+		input   interface{}
+		opts    []TableOption
+		header  []string
+		records [][]string
+		err     error
+	}{
+		{
+			"嵌套struct、可选指针与map的并集",
+			rows,
+			nil,
+			[]string{"id", "name", "home.city", "home.zip", "labels.env", "labels.team", "tags.0", "tags.1", "score", "created"},
+			[][]string{
+				{"1", "a", "x", "1", "prod", "", "t1", "", "9.5", "2024-01-02T03:04:05Z"},
+				{"2", "b,c", "", "", "dev", "core", "t2", "t3", "", "0001-01-01T00:00:00Z"},
+			},
+			nil,
+		},
+		{
+			"指定列与表头",
+			&rows,
+			[]TableOption{WithTableColumns("name", "labels/team", "missing"), WithTableSeparator("/"), WithTableHeaders(map[string]string{"labels/team": "Team"})},
+			[]string{"name", "Team", "missing"},
+			[][]string{{"a", "", ""}, {"b,c", "core", ""}},
+			nil,
+		},
+		{
+			"递归类型的nil指针只登记一层",
+			[]node{{Name: "a"}},
+			nil,
+			[]string{"Name", "Next.Name"},
+			[][]string{{"a", ""}},
+			nil,
+		},
+		{
+			"外层字段遮蔽嵌入字段",
+			[]outer{{Name: "outer", Base: Base{Name: "inner", Kind: "k"}}},
+			nil,
+			[]string{"Name", "Kind"},
+			[][]string{{"outer", "k"}},
+			nil,
+		},
+		{"map行", []map[string]int{{"b": 2}, {"a": 1}}, nil, []string{"a", "b"}, [][]string{{"", "2"}, {"1", ""}}, nil},
+		{"整数key按数值排序", []map[int]string{{10: "a", 2: "b"}, {1: "c"}}, nil, []string{"1", "2", "10"}, [][]string{{"", "b", "a"}, {"c", "", ""}}, nil},
+		{"下标按数值排序", [][]int{{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}}, nil, []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}, [][]string{{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}}, nil},
+		{"同一列既是叶子又是容器", []interface{}{map[string]interface{}{"a": 1}, map[string]interface{}{"a": []int{1}}}, nil, nil, nil, ErrTypeMismatch},
+		{"不同路径的列名相同", []map[string]interface{}{{"a.b": 1, "a": map[string]int{"b": 2}}}, nil, nil, nil, ErrKeyCollision},
+		{"指针环", []*node{loop}, nil, nil, nil, ErrCycle},
+		{"map环", []map[string]interface{}{selfMap}, nil, nil, nil, ErrCycle},
+		{"不是slice", record{}, nil, nil, nil, ErrTypeMismatch},
+	}
+
+	for _, tc := range testCases {
+		header, records, err := Table(tc.input, tc.opts...)
+		if !errors.Is(err, tc.err) || !reflect.DeepEqual(header, tc.header) || !reflect.DeepEqual(records, tc.records) {
+			t.Errorf("%s miss match: \n\tinput: %v\n\texpect:%q %q, %v\n\tgot:   %q %q, %v", tc.name, tc.input, tc.header, tc.records, tc.err, header, records, err)
+		}
+	}
+
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	w.Comma = ';'
+	if err := WriteCSV(w, rows, WithTableColumns("id", "name")); err != nil {
+		t.Fatal(err)
+	}
+	expect := "id;name\n1;a\n2;b,c\n"
+	if sb.String() != expect {
+		t.Errorf("WriteCSV miss match: \n\texpect:%q\n\tgot:   %q", expect, sb.String())
+	}
+
+	sb.Reset()
+	w = csv.NewWriter(&sb)
+	if err := WriteCSV(w, rows, WithTableColumns("name"), WithoutTableHeader()); err != nil {
+		t.Fatal(err)
+	}
+	if expect := "a\n\"b,c\"\n"; sb.String() != expect {
+		t.Errorf("WriteCSV miss match: \n\texpect:%q\n\tgot:   %q", expect, sb.String())
+	}
+}
@@ -0,0 +1,379 @@
+// 基于reflect_walker遍历的校验
+// 读取struct字段上形如`validate:"required,min=1,max=64,oneof=a b,regexp=^[a-z]+$"`的标签，
+// 以及按路径注册的规则（用于map等没有标签的数据），返回带路径的违规列表
+package validate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"bournex/reflect_walker"
+)
+
+const TagName = "validate"
+
+var ErrUnknownRule = errors.New("unknown rule")
+
+// 校验规则，value为节点的实际值，param为"="之后的参数，校验失败返回的error作为违规信息
+type Rule func(value interface{}, param string) error
+
+// 违规
+type Violation struct {
+	Path    reflect_walker.Path
+	Rule    string
+	Message string
+}
+
+func (v Violation) Error() string {
+	return fmt.Sprintf("%s: %s", v.Path, v.Message)
+}
+
+type Violations []Violation
+
+func (vs Violations) Error() string {
+	msgs := make([]string, len(vs))
+	for i, v := range vs {
+		msgs[i] = v.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type ruleSpec struct {
+	name  string
+	param string
+}
+
+type pathRule struct {
+	pattern []string // "*"匹配任意一级
+	rules   []ruleSpec
+}
+
+type Validator struct {
+	options []reflect_walker.WalkOption
+	rules   map[string]Rule
+	paths   []pathRule
+	regexps sync.Map // pattern -> *regexp.Regexp
+}
+
+// 创建校验器，opts会传给底层的walker，如reflect_walker.WithLeafTypes(time.Time{})
+// opts包含reflect_walker.WithParallelism时，自定义规则会被并发调用，需要是并发安全的
+// 规则需在校验前注册完成，Validate可并发调用
+func New(opts ...reflect_walker.WalkOption) *Validator {
+	v := &Validator{
+		options: opts,
+		rules:   map[string]Rule{},
+	}
+	v.rules["required"] = rule_required
+	v.rules["min"] = rule_min
+	v.rules["max"] = rule_max
+	v.rules["len"] = rule_len
+	v.rules["oneof"] = rule_oneof
+	v.rules["regexp"] = v.rule_regexp
+	return v
+}
+
+// 注册自定义规则，同名规则会被覆盖
+func (v *Validator) RegisterRule(name string, rule Rule) {
+	v.rules[name] = rule
+}
+
+// 按路径注册规则，路径以"."分隔，"*"匹配任意一级，如"users.*.name"
+func (v *Validator) RegisterPath(path string, rules string) {
+	v.paths = append(v.paths, pathRule{
+		pattern: strings.Split(path, "."),
+		rules:   parse_rules(rules),
+	})
+}
+
+// 校验in，返回按路径排序的违规列表，没有违规时返回nil
+func (v *Validator) Validate(ctx context.Context, in interface{}) Violations {
+	var (
+		mu         sync.Mutex // walker开启并发时routine会被同时调用
+		violations Violations
+		visited    = map[string]reflect.Kind{"": root_kind(in)}
+	)
+
+	routine := func(ctx context.Context, node reflect_walker.TreeNode) {
+		switch node.Type() {
+		case reflect_walker.NodeType_struct_member, reflect_walker.NodeType_map_pair, reflect_walker.NodeType_slice_member:
+		default:
+			return
+		}
+
+		path := node.Path()
+		value := node.Value().Interface()
+		mu.Lock()
+		visited[path.String()] = root_kind(value)
+		mu.Unlock()
+
+		var specs []ruleSpec
+		if tag, ok := node.Tag().Lookup(TagName); ok {
+			specs = parse_rules(tag)
+		}
+		for _, pr := range v.paths {
+			if match_path(pr.pattern, path) {
+				specs = append(specs, pr.rules...)
+			}
+		}
+
+		var found Violations
+		for _, spec := range specs {
+			rule, ok := v.rules[spec.name]
+			if !ok {
+				found = append(found, Violation{Path: path, Rule: spec.name, Message: ErrUnknownRule.Error()})
+				continue
+			}
+			if err := rule(value, spec.param); err != nil {
+				found = append(found, Violation{Path: path, Rule: spec.name, Message: err.Error()})
+				if spec.name == "required" {
+					// 值缺失时其余规则没有意义
+					break
+				}
+			}
+		}
+		if len(found) > 0 {
+			mu.Lock()
+			violations = append(violations, found...)
+			mu.Unlock()
+		}
+	}
+
+	// 校验不修改输入，同一个值可以被并发校验
+	opts := append([]reflect_walker.WalkOption{
+		reflect_walker.WithReadOnly(),
+		reflect_walker.WithContainerNodes(),
+		reflect_walker.WithRoutine(routine),
+	}, v.options...)
+	reflect_walker.NewTreeWalker(opts...).Walk(ctx, in)
+
+	violations = append(violations, v.missing(visited)...)
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Path.String() < violations[j].Path.String()
+	})
+	return violations
+}
+
+// 按路径注册了required的key在其所在的map中不存在
+func (v *Validator) missing(visited map[string]reflect.Kind) Violations {
+	var violations Violations
+	for _, pr := range v.paths {
+		if !has_rule(pr.rules, "required") {
+			continue
+		}
+		last := pr.pattern[len(pr.pattern)-1]
+		parentPattern := pr.pattern[:len(pr.pattern)-1]
+		if last == "*" {
+			continue
+		}
+
+		for parent, kind := range visited {
+			if kind != reflect.Map && kind != reflect.Struct {
+				continue
+			}
+			var segs []string
+			if parent != "" {
+				segs = strings.Split(parent, ".")
+			}
+			if !match_segments(parentPattern, segs) {
+				continue
+			}
+
+			full := append(append([]string{}, segs...), last)
+			if _, ok := visited[strings.Join(full, ".")]; ok {
+				continue
+			}
+			path := make(reflect_walker.Path, len(full))
+			for i, seg := range full {
+				path[i] = seg
+			}
+			violations = append(violations, Violation{Path: path, Rule: "required", Message: "is required"})
+		}
+	}
+	return violations
+}
+
+// 默认校验器
+var defaultValidator = New()
+
+// 使用默认校验器校验
+func Validate(ctx context.Context, in interface{}) Violations {
+	return defaultValidator.Validate(ctx, in)
+}
+
+func parse_rules(tag string) []ruleSpec {
+	var specs []ruleSpec
+	items := strings.Split(tag, ",")
+	for i := 0; i < len(items); i++ {
+		item := strings.TrimSpace(items[i])
+		if item == "" || item == "-" {
+			continue
+		}
+		name, param, _ := strings.Cut(item, "=")
+		if name == "regexp" {
+			// 正则中可能含有","，取剩余全部内容
+			param = strings.Join(append([]string{param}, items[i+1:]...), ",")
+			i = len(items)
+		}
+		specs = append(specs, ruleSpec{name: name, param: param})
+	}
+	return specs
+}
+
+func has_rule(specs []ruleSpec, name string) bool {
+	for _, spec := range specs {
+		if spec.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func match_path(pattern []string, path reflect_walker.Path) bool {
+	if len(pattern) != len(path) {
+		return false
+	}
+	for i, seg := range pattern {
+		if seg != "*" && seg != fmt.Sprint(path[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func match_segments(pattern []string, segs []string) bool {
+	if len(pattern) != len(segs) {
+		return false
+	}
+	for i, seg := range pattern {
+		if seg != "*" && seg != segs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func root_kind(in interface{}) reflect.Kind {
+	v := reflect.ValueOf(in)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v.Kind()
+}
+
+// 解开指针，nil返回无效值
+func indirect(value interface{}) reflect.Value {
+	v := reflect.ValueOf(value)
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func rule_required(value interface{}, _ string) error {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || v.IsZero() {
+		return errors.New("is required")
+	}
+	return nil
+}
+
+// 数值比较大小，字符串比较字符数，slice、map、数组比较长度
+func measure(value interface{}) (float64, bool) {
+	v := indirect(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), true
+	}
+	return 0, false
+}
+
+func rule_min(value interface{}, param string) error {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return fmt.Errorf("invalid min parameter %q", param)
+	}
+	if n, ok := measure(value); ok && n < limit {
+		return fmt.Errorf("must be at least %s", param)
+	}
+	return nil
+}
+
+func rule_max(value interface{}, param string) error {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return fmt.Errorf("invalid max parameter %q", param)
+	}
+	if n, ok := measure(value); ok && n > limit {
+		return fmt.Errorf("must be at most %s", param)
+	}
+	return nil
+}
+
+func rule_len(value interface{}, param string) error {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return fmt.Errorf("invalid len parameter %q", param)
+	}
+	if n, ok := measure(value); ok && n != limit {
+		return fmt.Errorf("length must be %s", param)
+	}
+	return nil
+}
+
+func rule_oneof(value interface{}, param string) error {
+	v := indirect(value)
+	if !v.IsValid() {
+		return nil
+	}
+	s := fmt.Sprint(v.Interface())
+	options := strings.Fields(param)
+	for _, o := range options {
+		if o == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of [%s]", strings.Join(options, " "))
+}
+
+func (v *Validator) rule_regexp(value interface{}, param string) error {
+	rv := indirect(value)
+	if !rv.IsValid() || rv.Kind() != reflect.String {
+		return nil
+	}
+
+	var re *regexp.Regexp
+	if cached, ok := v.regexps.Load(param); ok {
+		re = cached.(*regexp.Regexp)
+	} else {
+		compiled, err := regexp.Compile(param)
+		if err != nil {
+			return fmt.Errorf("invalid regexp %q", param)
+		}
+		v.regexps.Store(param, compiled)
+		re = compiled
+	}
+
+	if !re.MatchString(rv.String()) {
+		return fmt.Errorf("must match %s", param)
+	}
+	return nil
+}
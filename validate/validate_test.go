@@ -0,0 +1,153 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bournex/reflect_walker"
+)
+
+func Test_Validate(t *testing.T) {
+	type member struct {
+		Name string `validate:"required,max=8"`
+		Role string `validate:"oneof=admin user"`
+	}
+	type request struct {
+		Name    string   `validate:"required,min=1,max=64"`
+		Code    string   `validate:"regexp=^[a-z]{2,3}$"`
+		Age     int      `validate:"min=18"`
+		Tags    []string `validate:"max=2"`
+		Owner   *member  `validate:"required"`
+		Members []member
+	}
+
+	testCases := []struct {
+		name   string
+		input  interface{}
+		setup  func(v *Validator)
+		expect []string
+	}{
+		{
+			name: "合法struct",
+			input: &request{
+				Name:  "alice",
+				Code:  "cn",
+				Age:   20,
+				Owner: &member{Name: "bob", Role: "admin"},
+			},
+		},
+		{
+			name: "struct标签违规",
+			input: &request{
+				Code:    "CHN",
+				Age:     3,
+				Tags:    []string{"a", "b", "c"},
+				Members: []member{{Name: "carol", Role: "guest"}, {Name: "toolongname", Role: "user"}},
+			},
+			expect: []string{
+				"Age: must be at least 18",
+				"Code: must match ^[a-z]{2,3}$",
+				"Members.0.Role: must be one of [admin user]",
+				"Members.1.Name: must be at most 8",
+				"Name: is required",
+				"Owner: is required",
+				"Tags: must be at most 2",
+			},
+		},
+		{
+			name: "按路径注册规则",
+			input: map[string]interface{}{
+				"users": []interface{}{
+					map[string]interface{}{"name": "alice", "email": "a@x.io"},
+					map[string]interface{}{"email": "b"},
+				},
+			},
+			setup: func(v *Validator) {
+				v.RegisterPath("users.*.name", "required")
+				v.RegisterPath("users.*.email", "email")
+				v.RegisterPath("version", "required")
+				v.RegisterRule("email", func(value interface{}, _ string) error {
+					if s, _ := value.(string); !strings.Contains(s, "@") {
+						return errors.New("is not an email")
+					}
+					return nil
+				})
+			},
+			expect: []string{
+				"users.1.email: is not an email",
+				"users.1.name: is required",
+				"version: is required",
+			},
+		},
+		{
+			name: "未知规则",
+			input: &struct {
+				A int `validate:"foo"`
+			}{},
+			expect: []string{"A: unknown rule"},
+		},
+	}
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			// 串行与并发遍历结果一致
+			for _, validator := range []*Validator{New(), New(reflect_walker.WithParallelism(4))} {
+				if v.setup != nil {
+					v.setup(validator)
+				}
+
+				var got []string
+				for _, violation := range validator.Validate(context.Background(), v.input) {
+					got = append(got, violation.Error())
+				}
+				if !reflect.DeepEqual(got, v.expect) {
+					t.Errorf("miss match: \n\texpect:%q\n\tgot:   %q", v.expect, got)
+				}
+			}
+		})
+	}
+}
+
+// 校验不修改输入，map、slice字段仍是原来的值
+func Test_Validate_untouched(t *testing.T) {
+	type inner struct {
+		Port int `validate:"min=1"`
+	}
+	type config struct {
+		Name   string            `validate:"required"`
+		Labels map[string]string `validate:"max=4"`
+		Tags   []string
+		Inner  *inner
+		Ports  [2]int
+	}
+
+	cfg := config{
+		Name:   "svc",
+		Labels: map[string]string{"env": "prod"},
+		Tags:   []string{"a", "b"},
+		Inner:  &inner{Port: 80},
+		Ports:  [2]int{80, 443},
+	}
+	labels, tags, in := reflect.ValueOf(cfg.Labels).Pointer(), reflect.ValueOf(cfg.Tags).Pointer(), cfg.Inner
+	snapshot := cfg
+
+	done := make(chan Violations, 4)
+	for i := 0; i < cap(done); i++ {
+		go func() { done <- Validate(context.Background(), &cfg) }()
+	}
+	for i := 0; i < cap(done); i++ {
+		if got := <-done; got != nil {
+			t.Errorf("miss match: \n\texpect:%v\n\tgot:   %v", nil, got)
+		}
+	}
+
+	if !reflect.DeepEqual(cfg, snapshot) {
+		t.Errorf("miss match: \n\texpect:%+v\n\tgot:   %+v", snapshot, cfg)
+	}
+	if reflect.ValueOf(cfg.Labels).Pointer() != labels || reflect.ValueOf(cfg.Tags).Pointer() != tags || cfg.Inner != in {
+		t.Errorf("input replaced: \n\tLabels: %v\n\tTags:   %v\n\tInner:  %v",
+			reflect.ValueOf(cfg.Labels).Pointer() != labels, reflect.ValueOf(cfg.Tags).Pointer() != tags, cfg.Inner != in)
+	}
+}
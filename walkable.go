@@ -0,0 +1,201 @@
+package reflect_walker
+
+import (
+	"context"
+	"reflect"
+)
+
+// 自定义容器的子节点
+type WalkPair struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// 自定义容器接口，如有序map、环形缓冲区、懒加载树等以非导出或间接形式存储数据的类型
+// walker在使用反射遍历之前会先检查该接口，子节点按NodeType_map_pair类型交给routine处理
+// 只有指针实现该接口的类型，其值（如struct字段）在拷贝上调用，重建的结果为指针时取其指向的值写回
+type Walkable interface {
+	// 按顺序列出子节点，walker不会修改返回的slice，可以直接返回内部存储
+	WalkChildren() []WalkPair
+	// 接收遍历后的子节点（已应用修改，已剔除删除的节点，新插入的节点在末尾），返回重建后的容器
+	// 返回值会写回容器原来所在的位置，因此类型应与原容器一致
+	WalkRebuild(children []WalkPair) interface{}
+}
+
+type walkableFrame struct {
+	tr       *walker
+	ctx      context.Context
+	sc       *scope
+	w        Walkable
+	addr     bool // w是值的拷贝的地址
+	children []WalkPair
+	rebuilt  []WalkPair
+	inserted []WalkPair
+
+	i     int // 当前子节点
+	phase int // 当前子节点的处理阶段
+	node  *treeNode
+}
+
+func (tr *walker) walkable_frame(ctx context.Context, sc *scope, in interface{}) (frame, interface{}) {
+	w, ok := in.(Walkable)
+	if !ok {
+		// 只有指针实现了Walkable
+		p := reflect.New(reflect.TypeOf(in))
+		p.Elem().Set(reflect.ValueOf(in))
+		w = p.Interface().(Walkable)
+	}
+
+	children := w.WalkChildren()
+	n, act := tr.limit_elements(ctx, sc, len(children))
+	if act == limit_stop {
+		return nil, in
+	}
+
+	f := walkableFramePool.Get().(*walkableFrame)
+	// 遍历结果写入拷贝，不修改WalkChildren返回的slice
+	*f = walkableFrame{tr: tr, ctx: ctx, sc: sc, w: w, addr: !ok, children: append([]WalkPair(nil), children[:n]...), rebuilt: make([]WalkPair, 0, n)}
+	return f, nil
+}
+
+func (f *walkableFrame) next() step {
+	tr := f.tr
+	for f.i < len(f.children) {
+		child := &f.children[f.i]
+		switch f.phase {
+		case member_begin:
+			if tr.cancelled(f.ctx) {
+				// 未遍历的子节点原样保留
+				f.rebuilt = append(f.rebuilt, f.children[f.i:]...)
+				f.i = len(f.children)
+				continue
+			}
+			val := reflect.ValueOf(child.Value)
+			if act := f.limit(&val); act != limit_ok {
+				if act == limit_drop {
+					f.advance()
+				}
+				continue
+			}
+			f.phase = member_ready
+			if !tr.is_literal(&val) {
+				f.phase = member_walking
+				return step{kind: step_descend, sc: tr.enter_path(f.sc, child.Key), in: child.Value}
+			}
+		case member_ready:
+			if !tr.emits_nodes() {
+				f.rebuilt = append(f.rebuilt, *child)
+				f.advance()
+				continue
+			}
+
+			f.node = new_node(treeNode{
+				nType:      NodeType_map_pair,
+				parent:     f.sc,
+				elem:       child.Key,
+				insertable: true,
+			})
+			f.node.set_key(treeVariable{t: type_of(child.Key), value: child.Key})
+			f.node.set_value(treeVariable{t: type_of(child.Value), value: child.Value})
+			f.phase = member_node
+			return step{kind: step_node, node: f.node}
+		default:
+			return step{}
+		}
+	}
+	return step{}
+}
+
+func (f *walkableFrame) child_done(out interface{}) {
+	f.children[f.i].Value = out
+	f.phase = member_ready
+}
+
+func (f *walkableFrame) node_done(rt routine_action, override bool) {
+	child := f.children[f.i]
+	f.inserted = append(f.inserted, f.node.inserts...)
+	if rt != routine_delete {
+		if override {
+			child.Key = f.node.nKey.Interface()
+			child.Value = f.node.nValue.Interface()
+		}
+		f.rebuilt = append(f.rebuilt, child)
+	}
+	f.node.release()
+	f.node = nil
+	f.advance()
+}
+
+// 计入子节点并检查其值
+func (f *walkableFrame) limit(val *reflect.Value) limitAction {
+	tr := f.tr
+	if tr.limits == nil {
+		return limit_ok
+	}
+	key := f.children[f.i].Key
+	path := func() Path { return child_path(f.sc.path(0), key) }
+	if act := tr.count_node(f.ctx, path); act != limit_ok {
+		return act
+	}
+	nval, act := tr.limit_bytes(f.ctx, *val, path)
+	if act == limit_cut {
+		*val = nval
+		f.children[f.i].Value = nval.Interface()
+		act = limit_ok
+	}
+	return act
+}
+
+func (f *walkableFrame) advance() {
+	f.i++
+	f.phase = member_begin
+}
+
+func (f *walkableFrame) result() interface{} {
+	typ := reflect.TypeOf(f.w)
+	if f.addr {
+		typ = typ.Elem()
+	}
+	children := append(f.rebuilt, f.inserted...)
+	if cn := f.tr.visit_container(f.ctx, containerNode{kind: reflect.Map, typ: typ, sc: f.sc}); cn != nil {
+		children = append(children, cn.inserts...)
+	}
+
+	out := f.w.WalkRebuild(children)
+	if v := reflect.ValueOf(out); f.addr && v.Kind() == reflect.Pointer && !v.IsNil() && v.Type().Elem() == typ {
+		return v.Elem().Interface()
+	}
+	return out
+}
+
+func (f *walkableFrame) release() {
+	*f = walkableFrame{}
+	walkableFramePool.Put(f)
+}
+
+// 依次执行routine，遇到删除立即停止，字面量没有可删除的位置，不停止
+func (tr *walker) run_routines(ctx context.Context, node TreeNode) (rt routine_action, override bool) {
+	if tr.maxDepth != NoDepthLimit && len(tr.routines) > 0 {
+		depth := node.Depth()
+		ctx = context.WithValue(ctx, DepthCtxKey, &depth)
+	}
+	for _, r := range tr.routines {
+		r(ctx, node)
+
+		rt = node.getAction()
+		if rt == routine_delete && node.Type() != NodeType_literal {
+			break
+		} else if rt == routine_override {
+			override = true
+		}
+	}
+	return
+}
+
+// nil按interface{}处理
+func type_of(i interface{}) reflect.Type {
+	if i == nil {
+		return interfaceType
+	}
+	return reflect.TypeOf(i)
+}
@@ -2,16 +2,27 @@ package reflect_walker
 
 import (
 	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
 	"reflect"
+	"sync"
+	"sync/atomic"
+	"unsafe"
 )
 
 type Node_routine func(ctx context.Context, node TreeNode)
 type Walker interface {
 	Walk(context.Context, interface{}) interface{}
+	// 与Walk相同，同时返回遍历过程中记录的第一个错误，如ErrKeyCollision
+	WalkWithError(context.Context, interface{}) (interface{}, error)
 }
 
 const NoDepthLimit = -1
+
+// 设置了WithMaxDepth时，传给routine的ctx中记录节点所在容器的嵌套深度（*int），与TreeNode.Depth()相同
 const DepthCtxKey = "_reflect_walker_curr_depth"
+const stateCtxKey = "_reflect_walker_state"
 
 type WalkOption func(tw *walker)
 
@@ -36,6 +47,75 @@ func WithJsonableMap() WalkOption {
 	}
 }
 
+// 默认情况下，Set的值必须能转换为节点所在位置的声明类型，否则返回ErrTypeMismatch
+// 开启后，对于重建的map与slice（拷贝模式）允许写入不兼容类型的值，容器的key或value类型会放宽为interface{}
+// 仅当容器本身位于interface{}类型的位置（或是Walk的入参）时生效，struct字段等原地修改的位置仍做类型检查
+func WithLooseOverride() WalkOption {
+	return func(tw *walker) {
+		tw.looseOverride = true
+	}
+}
+
+// 将指定类型视为叶子节点，不再深入其内部，整体作为一个字面量节点交给routine读取或覆盖
+// 参数为类型的样例值，如WithLeafTypes(time.Time{}, big.Int{})，指向这些类型的指针同样视为叶子
+func WithLeafTypes(samples ...interface{}) WalkOption {
+	return func(tw *walker) {
+		if tw.leafTypes == nil {
+			tw.leafTypes = make(map[reflect.Type]struct{})
+		}
+		for _, sample := range samples {
+			if t := reflect.TypeOf(sample); t != nil {
+				tw.leafTypes[t] = struct{}{}
+			}
+		}
+	}
+}
+
+// 将实现了encoding.TextMarshaler的类型视为叶子，如time.Time、netip.Addr、big.Int
+func WithTextMarshalerLeaves() WalkOption {
+	return func(tw *walker) {
+		tw.leafIfaces = append(tw.leafIfaces, reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem())
+	}
+}
+
+// 将实现了json.Marshaler的类型视为叶子
+func WithJsonMarshalerLeaves() WalkOption {
+	return func(tw *walker) {
+		tw.leafIfaces = append(tw.leafIfaces, reflect.TypeOf((*json.Marshaler)(nil)).Elem())
+	}
+}
+
+// 将实现了fmt.Stringer的类型视为叶子，如url.URL
+func WithStringerLeaves() WalkOption {
+	return func(tw *walker) {
+		tw.leafIfaces = append(tw.leafIfaces, reflect.TypeOf((*fmt.Stringer)(nil)).Elem())
+	}
+}
+
+// struct成员与slice成员中的容器（map、slice、struct、指针等）在其内部遍历完成后，也作为节点交给routine
+// 默认只有map会为容器类型的值生成节点
+func WithContainerNodes() WalkOption {
+	return func(tw *walker) {
+		tw.containerNodes = true
+	}
+}
+
+// struct输出为map[string]interface{}，key为json标签名或字段名，匿名嵌入或带inline选项的struct展开到上一级，带omitempty的零值字段不输出
+// 此时struct成员节点支持Key().Set改名、Delete以及Insert新增字段，原struct不会被修改
+func WithStructAsMap() WalkOption {
+	return func(tw *walker) {
+		tw.structAsMap = true
+	}
+}
+
+// 不修改输入：指针指向的值、struct字段不会被写回，修改只体现在返回的新值中，数组输出为[]interface{}
+// 用于只读取的场景，如校验，同一个输入可以被并发遍历
+func WithReadOnly() WalkOption {
+	return func(tw *walker) {
+		tw.copying = true
+	}
+}
+
 func NewTreeWalker(wo ...WalkOption) Walker {
 	tw := &walker{maxDepth: NoDepthLimit}
 	for _, option := range wo {
@@ -45,216 +125,668 @@ func NewTreeWalker(wo ...WalkOption) Walker {
 }
 
 type walker struct {
-	maxDepth      int            // recursive depth
-	jsonable      bool           // make input json marshalable or let it be
-	forceOverride bool           // copy or in-place override
-	routines      []Node_routine // custom callback routine
+	maxDepth       int                       // recursive depth
+	jsonable       bool                      // make input json marshalable or let it be
+	forceOverride  bool                      // copy or in-place override
+	looseOverride  bool                      // allow type-changing override in copy mode
+	keyTransform   KeyTransform              // rename string keys
+	structAsMap    bool                      // output structs as map[string]interface{}
+	sortedMaps     bool                      // visit map entries in key order
+	keyLess        KeyLess                   // custom map key order
+	containerNodes bool                      // emit nodes for container members of structs and slices
+	leafTypes      map[reflect.Type]struct{} // types walked as a whole
+	leafIfaces     []reflect.Type            // interfaces whose implementations are walked as a whole
+	routines       []Node_routine            // custom callback routine
+	containers     []Container_routine       // callbacks on whole containers
+	parallelism    int                       // number of goroutines walking containers
+	pulled         bool                      // nodes are handed out by a NodeCursor
+	limits         *Limits                   // resource limits for untrusted input
+	copying        bool                      // never write through pointers into the input
+	elided         func(n int) string        // marker for members dropped by MaxElements
+	tooDeep        interface{}               // replaces containers beyond maxDepth, nil keeps them
+	plans          sync.Map                  // reflect.Type -> *typePlan
+
+	opaque    func(interface{}) interface{}         // replaces func, chan and unsafe.Pointer values, which get no node
+	intercept func(interface{}) (interface{}, bool) // rewrites every value before it is walked, true keeps the result as is
+	tagName   string                                // struct tag for field names, json by default
+	private   func(reflect.Type) bool               // also walks unexported fields of the struct types it accepts, read only
+
+	defaults          bool               // fill zero values with defaults
+	defaultsProviders []DefaultsProvider // custom default values
+	defaultPointers   bool               // allocate nil struct pointers whose type has defaults
+	defaultsCache     sync.Map           // reflect.Type -> whether the struct has defaults
 }
 
 func (tr *walker) Walk(ctx context.Context, in interface{}) interface{} {
+	out, _ := tr.WalkWithError(ctx, in)
+	return out
+}
+
+func (tr *walker) WalkWithError(ctx context.Context, in interface{}) (interface{}, error) {
 	if in == nil {
-		return in
+		return in, nil
 	}
 
+	ctx, state := tr.begin(ctx)
+	out := tr.walk(ctx, root_scope(), in)
+	return out, state.err
+}
+
+// 准备单次遍历的状态
+func (tr *walker) begin(ctx context.Context) (context.Context, *walkState) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
-	return tr.walk(ctx, in)
+	state := &walkState{}
+	if tr.parallelism > 1 {
+		state.workers = make(chan struct{}, tr.parallelism-1)
+	}
+	return context.WithValue(ctx, stateCtxKey, state), state
 }
 
-func (tr *walker) walk(ctx context.Context, in interface{}) interface{} {
-	if nctx, too_deep := tr.dive(ctx); too_deep {
-		return in
-	} else {
-		ctx = nctx
+// 是否生成节点，只有routine或游标会处理节点
+func (tr *walker) emits_nodes() bool {
+	return len(tr.routines) > 0 || tr.pulled
+}
+
+// 单次遍历的状态
+type walkState struct {
+	mu      sync.Mutex
+	err     error         // 第一个错误
+	workers chan struct{} // 并发遍历时空闲的worker
+
+	nodes   atomic.Int64 // 已遍历的容器成员数
+	bytes   atomic.Int64 // 已遍历的字符串字节数
+	stopped atomic.Bool  // 超出限制，停止遍历
+}
+
+// 记录遍历中的错误
+func (tr *walker) report(ctx context.Context, err error) {
+	if state, ok := ctx.Value(stateCtxKey).(*walkState); ok {
+		state.mu.Lock()
+		if state.err == nil {
+			state.err = err
+		}
+		state.mu.Unlock()
 	}
-	defer tr.rise(ctx)
+}
 
-	intyp := reflect.TypeOf(in)
-	// inval := reflect.ValueOf(in)
-
-	switch intyp.Kind() {
-	case reflect.Map:
-		in = tr.walk_map(ctx, in)
-	case reflect.Slice:
-		in = tr.walk_slice(ctx, in)
-	case reflect.Struct:
-		in = tr.walk_struct(ctx, in)
-	case reflect.Pointer:
-		in = tr.walk_pointer(ctx, in)
-	case reflect.Interface:
-		// do nothing
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, // 有符号数
-		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, // 无符号数
-		reflect.Float32, reflect.Float64, // 浮点数
-		reflect.Complex64, reflect.Complex128, // 复数
-		reflect.String, // 字符串
-		reflect.Bool:   // 布尔
-		in = tr.walk_literal(ctx, in, false)
-	default:
-	}
-	return in
-}
-
-func (tr *walker) walk_literal(ctx context.Context, in interface{}, settable bool) interface{} {
-	intyp := reflect.TypeOf(in)
+// 遍历in，需要执行routine的节点在当前goroutine中依次处理
+func (tr *walker) walk(ctx context.Context, sc *scope, in interface{}) interface{} {
+	e := tr.new_engine(ctx, sc, in)
+	for node := e.next(); node != nil; node = e.next() {
+		e.resume(tr.run_routines(ctx, node))
+	}
+	return e.out
+}
+
+type sliceFrame struct {
+	tr      *walker
+	ctx     context.Context
+	sc      *scope
+	inval   reflect.Value
+	loose   bool
+	members []walkedMember
+
+	i     int           // 当前成员
+	phase int           // 当前成员的处理阶段
+	cur   walkedMember  // 当前成员
+	val   reflect.Value // 当前成员解开interface后的值
+}
+
+func (tr *walker) slice_frame(ctx context.Context, sc *scope, in interface{}) (frame, interface{}) {
 	inval := reflect.ValueOf(in)
-	if settable {
-		intyp = intyp.Elem()
-		inval = inval.Elem()
+
+	// 排除掉有类型信息的nil值，设置了Container_routine时nil的slice同样可以追加
+	if inval.IsNil() && len(tr.containers) == 0 {
+		return nil, in
 	}
 
-	node := &treeNode{
-		nType: NodeType_literal,
+	n, act := tr.limit_elements(ctx, sc, inval.Len())
+	if act == limit_stop {
+		return nil, in
 	}
-	node.nValue = &treeVariable{node: node, t: intyp, value: inval.Interface()}
-	var override bool
 
-	for _, r := range tr.routines {
-		r(ctx, node)
-		rt := node.getAction()
+	f := sliceFramePool.Get().(*sliceFrame)
+	*f = sliceFrame{tr: tr, ctx: ctx, sc: sc, inval: inval, loose: tr.is_loose(sc), members: members_buf(f.members, n)}
+	if tr.can_parallel(ctx, sc, len(f.members)) {
+		// 并发遍历时各成员在worker中递归完成
+		tr.each(ctx, len(f.members), func(i int) {
+			f.members[i] = tr.walk_slice_member(ctx, sc, inval, i, f.loose)
+		})
+		f.i = len(f.members)
+	}
+	return f, nil
+}
 
-		if rt == routine_override {
-			override = true
+func (f *sliceFrame) next() step {
+	tr := f.tr
+	for f.i < len(f.members) {
+		switch f.phase {
+		case member_begin:
+			if tr.cancelled(f.ctx) {
+				f.i = len(f.members)
+				continue
+			}
+			f.cur, f.val = tr.slice_member_begin(f.inval, f.i)
+			if act := tr.limit_member(f.ctx, &f.cur, &f.val, f.member_path); act != limit_ok {
+				if act == limit_drop {
+					f.finish(walkedMember{deleted: true})
+				}
+				continue
+			}
+			if !tr.is_literal(&f.val) {
+				f.phase = member_walking
+				return step{kind: step_descend, sc: tr.enter_slot(tr.enter_index(f.sc, f.i), f.inval.Type().Elem()), in: f.val.Interface()}
+			}
+			f.phase = member_ready
+		case member_ready:
+			if node := tr.slice_member_node(f.sc, f.inval, f.i, f.val, f.loose); node != nil {
+				f.cur.node = node
+				f.phase = member_node
+				return step{kind: step_node, node: node}
+			}
+			f.finish(f.cur)
+		default:
+			return step{}
 		}
 	}
+	return step{}
+}
 
-	if override {
-		if settable {
-			inval.Set(node.nValue.rvalue())
-		} else {
-			nval := reflect.New(intyp).Elem()
-			newVal := reflect.ValueOf(node.nValue.Interface())
-			nval.Set(newVal)
-			in = nval.Interface()
-		}
+func (f *sliceFrame) child_done(out interface{}) {
+	f.cur, f.val = f.tr.slice_member_walked(f.inval, f.i, out, f.loose)
+	if !f.tr.containerNodes {
+		f.finish(f.cur)
+		return
 	}
+	f.phase = member_ready
+}
 
-	return in
+func (f *sliceFrame) node_done(rt routine_action, override bool) {
+	f.finish(f.tr.member_done(f.cur, rt, override))
 }
 
-func (tr *walker) walk_slice(ctx context.Context, in interface{}) interface{} {
-	intyp := reflect.TypeOf(in)
-	inval := reflect.ValueOf(in)
+func (f *sliceFrame) member_path() Path {
+	return child_path(f.sc.path(0), f.i)
+}
+
+func (f *sliceFrame) finish(m walkedMember) {
+	m.walked = true
+	f.members[f.i] = m
+	f.i++
+	f.phase = member_begin
+}
 
-	// 排除掉有类型信息的nil值
-	if inval.IsNil() {
-		return in
+func (f *sliceFrame) result() interface{} {
+	tr := f.tr
+	cn := tr.visit_container(f.ctx, containerNode{kind: reflect.Slice, typ: f.inval.Type(), sc: f.sc,
+		value: treeVariable{slot: f.inval.Type().Elem(), loose: f.loose}})
+	if f.inval.IsNil() && !cn.changed() {
+		return f.inval.Interface()
+	}
+
+	mdval := tr.assemble_slice(f.inval, f.members, f.loose)
+	if n := f.inval.Len() - len(f.members); n > 0 && tr.elided != nil {
+		// 被截断的成员数
+		marker := reflect.ValueOf(tr.elided(n))
+		if f.loose && !marker.Type().AssignableTo(mdval.Type().Elem()) {
+			mdval = widen_slice(mdval)
+		}
+		if marker.Type().AssignableTo(mdval.Type().Elem()) {
+			mdval = push(mdval, marker)
+		}
 	}
+	if cn != nil {
+		mdval = tr.append_members(mdval, &cn.value, cn.appends, f.loose)
+	}
+	return mdval.Interface()
+}
 
-	mdval := reflect.MakeSlice(intyp, 0, inval.Cap())
+func (f *sliceFrame) release() {
+	*f = sliceFrame{members: clear_members(f.members)}
+	sliceFramePool.Put(f)
+}
 
-	for i := 0; i < inval.Len(); i++ {
+// 复用成员缓冲区
+func members_buf(buf []walkedMember, n int) []walkedMember {
+	if cap(buf) < n {
+		return make([]walkedMember, n)
+	}
+	return buf[:n]
+}
 
-		val := inval.Index(i)
-		val = tr.unpack_value(val)
+func clear_members(buf []walkedMember) []walkedMember {
+	for i := range buf {
+		buf[i] = walkedMember{}
+	}
+	return buf[:0]
+}
 
-		if !tr.is_literal(&val) {
-			val = reflect.ValueOf(tr.walk(ctx, val.Interface()))
-			mdval = reflect.Append(mdval, val)
+// 按原顺序组装遍历后的slice
+func (tr *walker) assemble_slice(inval reflect.Value, members []walkedMember, loose bool) reflect.Value {
+	mdval := make_slice(inval.Type(), 0, inval.Cap())
+	for i, m := range members {
+		if !m.walked {
+			// 遍历被取消，原样保留
+			mdval = push(mdval, inval.Index(i))
 			continue
 		}
 
-		node := &treeNode{
-			nType: NodeType_slice_member,
+		if m.node != nil {
+			mdval = tr.append_members(mdval, m.node.nValue.(*treeVariable), m.node.before, loose)
+		}
+		if !m.deleted {
+			if loose && !m.val.Type().AssignableTo(mdval.Type().Elem()) {
+				mdval = widen_slice(mdval)
+			}
+			mdval = push(mdval, m.val)
+		}
+		if m.node != nil {
+			mdval = tr.append_members(mdval, m.node.nValue.(*treeVariable), m.node.after, loose)
+			m.node.release()
 		}
-		node.nValue = &treeVariable{node: node, t: val.Type(), value: val.Interface()}
+	}
+	return mdval
+}
 
-		override := false
-		var rt routine_action
-		for _, r := range tr.routines {
-			r(ctx, node)
+// 遍历后的容器成员，在所有成员遍历完成后按原顺序组装
+type walkedMember struct {
+	key     reflect.Value
+	val     reflect.Value
+	elem    reflect.Value // map中原始的key，用于生成路径
+	renamed bool          // key经过转换或被routine修改
+	node    *treeNode     // 没有routine或没有生成节点时为nil
+	deleted bool
+	walked  bool // 遍历被取消时为false
+}
 
-			rt = node.getAction()
-			if rt == routine_delete {
-				break
-			} else if rt == routine_override {
-				override = true
-			}
+// 并发遍历时在worker中完整地处理一个slice成员
+func (tr *walker) walk_slice_member(ctx context.Context, sc *scope, inval reflect.Value, i int, loose bool) walkedMember {
+	m, val := tr.slice_member_begin(inval, i)
+	switch tr.limit_member(ctx, &m, &val, func() Path { return child_path(sc.path(0), i) }) {
+	case limit_drop:
+		return walkedMember{deleted: true, walked: true}
+	case limit_stop:
+		return walkedMember{}
+	}
+	if !tr.is_literal(&val) {
+		out := tr.walk(ctx, fork_scope(tr.enter_slot(tr.enter_index(sc, i), inval.Type().Elem())), val.Interface())
+		if m, val = tr.slice_member_walked(inval, i, out, loose); !tr.containerNodes {
+			m.walked = true
+			return m
 		}
+	}
 
-		if rt == routine_delete {
-			continue
+	node := tr.slice_member_node(sc, inval, i, val, loose)
+	if node == nil {
+		m.walked = true
+		return m
+	}
+	m.node = node
+	rt, override := tr.run_routines(ctx, node)
+	m = tr.member_done(m, rt, override)
+	m.walked = true
+	return m
+}
+
+func (tr *walker) slice_member_begin(inval reflect.Value, i int) (walkedMember, reflect.Value) {
+	raw := inval.Index(i)
+	return walkedMember{val: raw}, tr.unpack_value(raw)
+}
+
+// 子值遍历完成
+func (tr *walker) slice_member_walked(inval reflect.Value, i int, out interface{}, loose bool) (walkedMember, reflect.Value) {
+	nval := walked_value(out)
+	if loose || nval.Type().AssignableTo(inval.Type().Elem()) {
+		return walkedMember{val: nval}, nval
+	}
+	// 遍历后类型发生变化（如jsonable）且无法放回原位置，保留原值
+	return tr.slice_member_begin(inval, i)
+}
+
+// 生成slice成员的节点，没有routine时返回nil，值未改变时写回原值，避免重新装箱
+func (tr *walker) slice_member_node(sc *scope, inval reflect.Value, i int, val reflect.Value, loose bool) *treeNode {
+	if !tr.emits_nodes() {
+		return nil
+	}
+
+	node := new_node(treeNode{
+		nType:      NodeType_slice_member,
+		defaulted:  tr.defaults && is_defaulted(sc),
+		parent:     sc,
+		index:      i,
+		insertable: true,
+	})
+	node.set_value(treeVariable{t: val.Type(), slot: inval.Type().Elem(), loose: loose, rv: val})
+	return node
+}
+
+// 节点处理完成，应用修改
+func (tr *walker) member_done(m walkedMember, rt routine_action, override bool) walkedMember {
+	if override {
+		if m.key.IsValid() {
+			nkey := m.node.nKey.rvalue()
+			m.renamed = m.renamed || nkey.Interface() != m.elem.Interface()
+			m.key = nkey
 		}
+		m.val = m.node.nValue.rvalue()
+	}
+	m.deleted = rt == routine_delete
+	return m
+}
 
-		if override {
-			val = reflect.ValueOf(node.nValue.Interface())
+// 追加插入的slice成员
+func (tr *walker) append_members(mdval reflect.Value, tv *treeVariable, values []interface{}, loose bool) reflect.Value {
+	for _, v := range values {
+		val := tv.reflect_value(v)
+		if loose && !val.Type().AssignableTo(mdval.Type().Elem()) {
+			mdval = widen_slice(mdval)
 		}
+		mdval = push(mdval, val)
+	}
+	return mdval
+}
 
-		mdval = reflect.Append(mdval, val)
+// 创建可寻址的slice，以便push原地追加
+func make_slice(typ reflect.Type, len, cap int) reflect.Value {
+	s := reflect.New(typ).Elem()
+	s.Set(reflect.MakeSlice(typ, len, cap))
+	return s
+}
+
+// 向make_slice创建的slice追加元素，容量足够时不分配，reflect.Append每次调用都会分配
+func push(s reflect.Value, v reflect.Value) reflect.Value {
+	n := s.Len()
+	if n == s.Cap() {
+		ns := make_slice(s.Type(), n, 2*n+1)
+		reflect.Copy(ns, s)
+		s = ns
 	}
-	return mdval.Interface()
+	s.SetLen(n + 1)
+	s.Index(n).Set(v)
+	return s
+}
+
+// 写入插入的map键值对，ktv、vtv为key与值所在位置的变量
+func (tr *walker) insert_pairs(m reflect.Value, ktv, vtv *treeVariable, pairs []WalkPair, loose bool) reflect.Value {
+	for _, pair := range pairs {
+		key := ktv.reflect_value(pair.Key)
+		val := vtv.reflect_value(pair.Value)
+		mtyp := m.Type()
+		if loose && (!key.Type().AssignableTo(mtyp.Key()) || !val.Type().AssignableTo(mtyp.Elem())) {
+			m = widen_map(m, key.Type(), val.Type())
+		}
+		m.SetMapIndex(key, val)
+	}
+	return m
+}
+
+type mapFrame struct {
+	tr          *walker
+	ctx         context.Context
+	sc          *scope
+	inval       reflect.Value
+	walkmapType reflect.Type
+	loose       bool
+	keys, vals  reflect.Value
+	total       int // 截断前的键值对数
+	members     []walkedMember
+
+	i     int           // 当前键值对
+	phase int           // 当前键值对的处理阶段
+	cur   walkedMember  // 当前键值对
+	val   reflect.Value // 当前值解开interface后的值
 }
 
-func (tr *walker) walk_map(ctx context.Context, in interface{}) interface{} {
+func (tr *walker) map_frame(ctx context.Context, sc *scope, in interface{}) (frame, interface{}) {
 	intyp := reflect.TypeOf(in)
 	inval := reflect.ValueOf(in)
 
-	// 排除掉有类型信息的nil值
-	if inval.IsNil() {
-		return in
+	// 排除掉有类型信息的nil值，设置了Container_routine时nil的map同样可以插入
+	if inval.IsNil() && len(tr.containers) == 0 {
+		return nil, in
 	}
 
-	var (
-		walkmap     reflect.Value
-		walkmapType reflect.Type = intyp
-	)
-
+	f := mapFramePool.Get().(*mapFrame)
+	*f = mapFrame{tr: tr, ctx: ctx, sc: sc, inval: inval, walkmapType: intyp, loose: tr.is_loose(sc), members: f.members}
 	if tr.jsonable {
-		walkmapType = reflect.MapOf(reflect.TypeOf(""), intyp.Elem())
+		f.walkmapType = reflect.MapOf(reflect.TypeOf(""), intyp.Elem())
+	}
+	n, act := tr.limit_elements(ctx, sc, inval.Len())
+	if act == limit_stop {
+		f.release()
+		return nil, in
+	}
+	f.keys, f.vals = tr.map_entries(inval, n)
+	f.total = inval.Len()
+	f.members = members_buf(f.members, n)
+	if tr.can_parallel(ctx, sc, len(f.members)) {
+		// 并发遍历时各键值对在worker中递归完成
+		tr.each(ctx, len(f.members), func(i int) {
+			f.members[i] = tr.walk_map_pair(ctx, sc, f.keys.Index(i), f.vals.Index(i), f.walkmapType, f.loose)
+		})
+		f.i = len(f.members)
 	}
-	walkmap = reflect.MakeMapWithSize(walkmapType, inval.Len())
+	return f, nil
+}
 
-	iter := inval.MapRange()
-	for iter.Next() {
-		key := iter.Key()
-		val := iter.Value()
+func (f *mapFrame) next() step {
+	tr := f.tr
+	for f.i < len(f.members) {
+		switch f.phase {
+		case member_begin:
+			if tr.cancelled(f.ctx) {
+				f.i = len(f.members)
+				continue
+			}
+			f.cur, f.val = tr.map_pair_begin(f.keys.Index(f.i), f.vals.Index(f.i))
+			if act := tr.limit_member(f.ctx, &f.cur, &f.val, f.member_path); act != limit_ok {
+				if act == limit_drop {
+					f.finish(walkedMember{deleted: true})
+				}
+				continue
+			}
+			if !tr.is_literal(&f.val) {
+				f.phase = member_walking
+				return step{kind: step_descend, sc: tr.enter_slot(tr.enter_key(f.sc, f.cur.elem), f.cur.val.Type()), in: f.val.Interface()}
+			}
+			f.phase = member_ready
+		case member_ready:
+			if node := tr.map_pair_node(f.sc, f.cur, f.val, f.walkmapType, f.loose); node != nil {
+				f.cur.node = node
+				f.phase = member_node
+				return step{kind: step_node, node: node}
+			}
+			f.finish(f.cur)
+		default:
+			return step{}
+		}
+	}
+	return step{}
+}
 
-		key = tr.unpack_value(key)
-		val = tr.unpack_value(val)
+func (f *mapFrame) child_done(out interface{}) {
+	f.val = f.tr.map_pair_walked(&f.cur, f.val, out, f.walkmapType, f.loose)
+	f.phase = member_ready
+}
 
-		if !tr.is_literal(&val) {
-			val = reflect.ValueOf(tr.walk(ctx, val.Interface()))
-		}
+func (f *mapFrame) node_done(rt routine_action, override bool) {
+	f.finish(f.tr.member_done(f.cur, rt, override))
+}
 
-		node := &treeNode{
-			nType: NodeType_map_pair,
-		}
-		node.nKey = &treeVariable{node: node, t: key.Type(), value: key.Interface()}
-		node.nValue = &treeVariable{node: node, t: val.Type(), value: val.Interface()}
+func (f *mapFrame) member_path() Path {
+	return child_path(f.sc.path(0), f.cur.elem.Interface())
+}
 
-		var (
-			rt       routine_action
-			override bool
-		)
+func (f *mapFrame) finish(m walkedMember) {
+	m.walked = true
+	f.members[f.i] = m
+	f.i++
+	f.phase = member_begin
+}
 
-		for _, r := range tr.routines {
-			r(ctx, node)
+func (f *mapFrame) result() interface{} {
+	tr := f.tr
+	cn := tr.visit_container(f.ctx, containerNode{kind: reflect.Map, typ: f.inval.Type(), sc: f.sc,
+		key:   treeVariable{slot: f.walkmapType.Key(), loose: f.loose && !tr.jsonable},
+		value: treeVariable{slot: f.walkmapType.Elem(), loose: f.loose}})
+	if f.inval.IsNil() && !cn.changed() {
+		return f.inval.Interface()
+	}
 
-			rt = node.getAction()
-			if rt == routine_delete {
-				break
-			} else if rt == routine_override {
-				override = true
+	walkmap := reflect.MakeMapWithSize(f.walkmapType, len(f.members))
+	var (
+		kt       keyTracker
+		inserted []*treeNode
+	)
+
+	for i, m := range f.members {
+		if !m.walked {
+			// 遍历被取消，类型允许时原样保留
+			key, val := f.keys.Index(i), f.vals.Index(i)
+			if key.Type().AssignableTo(walkmap.Type().Key()) && val.Type().AssignableTo(walkmap.Type().Elem()) {
+				walkmap.SetMapIndex(key, val)
 			}
+			continue
 		}
 
-		if rt == routine_delete {
+		if m.node != nil && len(m.node.inserts) > 0 {
+			inserted = append(inserted, m.node)
+		} else {
+			m.node.release()
+		}
+		if m.deleted {
 			continue
 		}
 
-		if override {
-			key = reflect.ValueOf(node.nKey.Interface())
-			val = reflect.ValueOf(node.nValue.Interface())
+		mtyp := walkmap.Type()
+		if f.loose && (!m.key.Type().AssignableTo(mtyp.Key()) || !m.val.Type().AssignableTo(mtyp.Elem())) {
+			walkmap = widen_map(walkmap, m.key.Type(), m.val.Type())
+		}
+		if kt.admit(f.ctx, tr, walkmap, m.key, m.renamed, func() Path { return child_path(f.sc.path(0), m.elem.Interface()) }) {
+			walkmap.SetMapIndex(m.key, m.val)
 		}
+	}
 
-		walkmap.SetMapIndex(key, val)
+	for _, node := range inserted {
+		walkmap = tr.insert_pairs(walkmap, node.nKey.(*treeVariable), node.nValue.(*treeVariable), node.inserts, f.loose)
+		node.release()
+	}
+	if cn != nil {
+		walkmap = tr.insert_pairs(walkmap, &cn.key, &cn.value, cn.inserts, f.loose)
+	}
+
+	if n := f.total - len(f.members); n > 0 && tr.elided != nil && f.loose {
+		// 被截断的键值对数
+		key, marker := reflect.ValueOf(elidedKey), reflect.ValueOf(tr.elided(n))
+		mtyp := walkmap.Type()
+		if !key.Type().AssignableTo(mtyp.Key()) || !marker.Type().AssignableTo(mtyp.Elem()) {
+			walkmap = widen_map(walkmap, key.Type(), marker.Type())
+		}
+		walkmap.SetMapIndex(key, marker)
 	}
 	return walkmap.Interface()
 }
 
-func (tr *walker) walk_struct(ctx context.Context, in interface{}) interface{} {
+// map被截断时记录省略数量的key
+const elidedKey = "…"
+
+func (f *mapFrame) release() {
+	*f = mapFrame{members: clear_members(f.members)}
+	mapFramePool.Put(f)
+}
+
+// 并发遍历时在worker中完整地处理一个键值对
+func (tr *walker) walk_map_pair(ctx context.Context, sc *scope, key, val reflect.Value, walkmapType reflect.Type, loose bool) walkedMember {
+	m, uval := tr.map_pair_begin(key, val)
+	switch tr.limit_member(ctx, &m, &uval, func() Path { return child_path(sc.path(0), m.elem.Interface()) }) {
+	case limit_drop:
+		return walkedMember{deleted: true, walked: true}
+	case limit_stop:
+		return walkedMember{}
+	}
+	if !tr.is_literal(&uval) {
+		out := tr.walk(ctx, fork_scope(tr.enter_slot(tr.enter_key(sc, m.elem), val.Type())), uval.Interface())
+		uval = tr.map_pair_walked(&m, uval, out, walkmapType, loose)
+	}
+
+	node := tr.map_pair_node(sc, m, uval, walkmapType, loose)
+	if node == nil {
+		m.walked = true
+		return m
+	}
+	m.node = node
+	rt, override := tr.run_routines(ctx, node)
+	m = tr.member_done(m, rt, override)
+	m.walked = true
+	return m
+}
+
+func (tr *walker) map_pair_begin(key, val reflect.Value) (walkedMember, reflect.Value) {
+	elem := tr.unpack_value(key)
+	nkey, renamed := tr.transform_key(elem)
+	return walkedMember{key: nkey, val: val, elem: elem, renamed: renamed}, tr.unpack_value(val)
+}
+
+// 子值遍历完成，类型发生变化（如jsonable）且无法放回原位置时保留原值
+func (tr *walker) map_pair_walked(m *walkedMember, val reflect.Value, out interface{}, walkmapType reflect.Type, loose bool) reflect.Value {
+	nval := walked_value(out)
+	if loose || nval.Type().AssignableTo(walkmapType.Elem()) {
+		val = nval
+	}
+	m.val = val
+	return val
+}
+
+// 生成键值对的节点，没有routine时返回nil，值未改变时写回原值，避免重新装箱
+func (tr *walker) map_pair_node(sc *scope, m walkedMember, val reflect.Value, walkmapType reflect.Type, loose bool) *treeNode {
+	if !tr.emits_nodes() {
+		return nil
+	}
+
+	node := new_node(treeNode{
+		nType:      NodeType_map_pair,
+		defaulted:  tr.defaults && is_defaulted(sc),
+		parent:     sc,
+		mapKey:     m.elem,
+		insertable: true,
+	})
+	// jsonable模式下key必须保持为字符串，不允许放宽
+	node.set_key(treeVariable{t: m.key.Type(), slot: walkmapType.Key(), loose: loose && !tr.jsonable, rv: m.key})
+	node.set_value(treeVariable{t: val.Type(), slot: walkmapType.Elem(), loose: loose, rv: val})
+	return node
+}
+
+type structFrame struct {
+	tr       *walker
+	ctx      context.Context
+	sc       *scope
+	in       interface{}
+	inval    reflect.Value
+	intyp    reflect.Type
+	writable bool
+	asMap    bool
+	fields   []fieldPlan
+
+	outmap   reflect.Value
+	kt       keyTracker
+	inlined  []reflect.Value
+	inserted []*treeNode
+
+	i         int // 当前字段
+	phase     int // 当前字段的处理阶段
+	key       reflect.Value
+	val       reflect.Value
+	renamed   bool
+	defaulted bool
+	node      *treeNode
+
+	j int // 当前展开的嵌入struct
+}
+
+func (tr *walker) struct_frame(ctx context.Context, sc *scope, in interface{}) (frame, interface{}) {
 	intyp := reflect.TypeOf(in)
 	inval := reflect.ValueOf(in)
 
@@ -262,135 +794,422 @@ func (tr *walker) walk_struct(ctx context.Context, in interface{}) interface{} {
 	writable := kind == reflect.Pointer || kind == reflect.Interface
 	if writable {
 		if inval.IsNil() {
-			return in
+			return nil, in
 		}
 		inval = inval.Elem()
 		intyp = intyp.Elem()
+		// 不修改输入时按值处理
+		writable = !tr.copying
 	}
 
-	for i := 0; i < inval.NumField(); i++ {
-		val := inval.Field(i)
-		typ := intyp.Field(i)
+	// 输出为map时同样不修改原值
+	asMap := tr.struct_as_map()
+	if !writable || asMap {
+		// 结构体值无法原地修改，修改其拷贝并返回拷贝
+		cp := reflect.New(intyp).Elem()
+		cp.Set(inval)
+		inval = cp
+	}
 
-		if !val.CanInterface() {
-			// 只walk公有成员
-			continue
-		}
+	f := structFramePool.Get().(*structFrame)
+	*f = structFrame{tr: tr, ctx: ctx, sc: sc, in: in, inval: inval, intyp: intyp,
+		writable: writable, asMap: asMap, fields: tr.plan(intyp).fields}
+	if asMap {
+		f.outmap = reflect.ValueOf(make(map[string]interface{}, inval.NumField()))
+	}
+	return f, nil
+}
+
+func (f *structFrame) next() step {
+	tr := f.tr
+	for f.i < len(f.fields) {
+		fp := &f.fields[f.i]
+		switch f.phase {
+		case member_begin:
+			if tr.cancelled(f.ctx) {
+				f.i = len(f.fields)
+				f.inlined = nil
+				continue
+			}
+			val := f.inval.Field(fp.index)
+			typ := fp.field
+
+			f.key, f.renamed = fp.key, false
+			if f.asMap {
+				if fp.skip {
+					f.i++
+					continue
+				}
+				if fp.inline {
+					// 匿名嵌入的struct展开到上一级，非公有的嵌入struct同样展开其公有字段
+					f.inlined = append(f.inlined, copied_field(val))
+					f.i++
+					continue
+				}
+				f.key, f.renamed = tr.transform_key(fp.jsonKey)
+				f.renamed = f.renamed || fp.name != typ.Name
+			}
 
-		if !tr.is_literal(&val) {
-			val = reflect.ValueOf(tr.walk(ctx, val.Interface()))
+			if !fp.exported {
+				if tr.private == nil || !tr.private(f.intyp) {
+					// 只walk公有成员
+					f.i++
+					continue
+				}
+				val = copied_field(val)
+			}
 
-			if writable {
-				inval.Field(i).Set(val)
+			f.defaulted = false
+			fsc := f.sc
+			if tr.defaults && val.IsZero() {
+				dv, ok, err := tr.default_value(f.sc, f.intyp, typ)
+				if err != nil {
+					tr.report(f.ctx, &PathError{Path: child_path(f.sc.path(0), fp.elem), Err: err})
+				}
+				if ok {
+					val.Set(dv)
+					f.defaulted = true
+					if typ.Type.Kind() == reflect.Pointer && typ.Type.Elem().Kind() == reflect.Struct {
+						fsc = with_allocated(fsc, typ.Type.Elem())
+					}
+				}
+			}
+			if tr.defaults {
+				fsc = with_defaulted(fsc, f.defaulted)
+			}
+			if f.asMap && fp.omitEmpty && is_empty_value(val) {
+				f.i++
+				continue
 			}
 
-			continue
-		}
+			// interface类型的字段按实际值处理
+			f.val = tr.unpack_value(val)
 
-		node := &treeNode{
-			nType: NodeType_struct_member,
+			if act := f.limit(); act != limit_ok {
+				if act == limit_drop {
+					f.advance()
+				}
+				continue
+			}
+			if !tr.is_literal(&f.val) {
+				f.phase = member_walking
+				return step{kind: step_descend, sc: tr.enter_slot(tr.enter_path(fsc, fp.elem), typ.Type), in: f.val.Interface()}
+			}
+			f.phase = member_ready
+		case member_ready:
+			if !tr.emits_nodes() {
+				f.admit(f.key, f.val)
+				f.advance()
+				continue
+			}
+
+			var keySlot, slot reflect.Type = nil, fp.field.Type
+			if f.asMap {
+				keySlot, slot = f.key.Type(), interfaceType
+			}
+
+			f.node = new_node(treeNode{
+				nType:      NodeType_struct_member,
+				defaulted:  f.defaulted,
+				parent:     f.sc,
+				elem:       fp.elem,
+				tag:        fp.field.Tag,
+				insertable: f.asMap,
+			})
+			f.node.set_key(treeVariable{t: f.key.Type(), slot: keySlot, rv: f.key})
+			f.node.set_value(treeVariable{t: f.val.Type(), slot: slot, rv: f.val})
+			f.phase = member_node
+			return step{kind: step_node, node: f.node}
+		default:
+			return step{}
 		}
-		node.nKey = &treeVariable{node: node, t: reflect.TypeOf(""), value: typ.Name}
-		node.nValue = &treeVariable{node: node, t: val.Type(), value: val.Interface()}
+	}
 
-		override := false
-		var rt routine_action
-		for _, r := range tr.routines {
-			r(ctx, node)
+	// 外层字段优先于嵌入struct的字段，嵌入的struct在所有字段之后展开
+	if f.j < len(f.inlined) && f.phase == member_begin {
+		f.phase = member_walking
+		return step{kind: step_descend, sc: inline_scope(f.sc), in: f.inlined[f.j].Interface()}
+	}
+	return step{}
+}
 
-			rt = node.getAction()
-			if rt == routine_delete {
-				// struct成员不支持delete，与blank效果一样
-				break
-			} else if rt == routine_override {
-				override = true
+func (f *structFrame) child_done(out interface{}) {
+	nval := walked_value(out)
+	if f.i >= len(f.fields) {
+		// 展开的嵌入struct
+		if nval.Kind() == reflect.Map {
+			iter := nval.MapRange()
+			for iter.Next() {
+				if !f.outmap.MapIndex(iter.Key()).IsValid() {
+					f.outmap.SetMapIndex(iter.Key(), iter.Value())
+				}
 			}
 		}
+		f.j++
+		f.phase = member_begin
+		return
+	}
 
-		if override && writable {
-			val = reflect.ValueOf(node.nValue.Interface())
-			inval.Field(i).Set(val)
+	fp := &f.fields[f.i]
+	if f.asMap {
+		f.val = nval
+	} else {
+		if nval.Type().AssignableTo(fp.field.Type) {
+			f.field().Set(nval)
 		}
+		f.val = f.tr.unpack_value(f.field())
 	}
-	// -
-	return in
+
+	if !f.tr.containerNodes {
+		f.admit(f.key, f.val)
+		f.advance()
+		return
+	}
+	f.phase = member_ready
 }
 
-func (tr *walker) walk_pointer(ctx context.Context, in interface{}) interface{} {
-	intyp := reflect.TypeOf(in)
-	inval := reflect.ValueOf(in)
+func (f *structFrame) node_done(rt routine_action, override bool) {
+	node := f.node
+	f.node = nil
+	defer f.advance()
+
+	if !f.asMap {
+		// 原地修改的struct成员不支持delete，与blank效果一样
+		if override {
+			f.field().Set(node.nValue.rvalue())
+		}
+		node.release()
+		return
+	}
 
-	typ := intyp.Elem()
-	if typ.Kind() == reflect.Map {
-		in = tr.walk_map(ctx, in)
-	} else if typ.Kind() == reflect.Slice {
-		in = tr.walk_slice(ctx, in)
-	} else if typ.Kind() == reflect.Struct {
-		in = tr.walk_struct(ctx, in)
+	if rt != routine_delete {
+		key, val := f.key, f.val
+		if override {
+			nkey := node.nKey.rvalue()
+			f.renamed = f.renamed || nkey.Interface() != key.Interface()
+			key = nkey
+			val = node.nValue.rvalue()
+		}
+		f.admit(key, val)
+	}
+	if len(node.inserts) > 0 {
+		f.inserted = append(f.inserted, node)
 	} else {
-		switch typ.Kind() {
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, // 有符号数
-			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, // 无符号数
-			reflect.Float32, reflect.Float64, // 浮点数
-			reflect.Complex64, reflect.Complex128, // 复数
-			reflect.String, // 字符串
-			reflect.Bool:   // 布尔
-			in = tr.walk_literal(ctx, inval.Interface(), true)
+		node.release()
+	}
+}
+
+// 计入字段并检查其值，原地修改时截断的值写回字段
+func (f *structFrame) limit() limitAction {
+	tr := f.tr
+	if tr.limits == nil {
+		return limit_ok
+	}
+	elem := f.fields[f.i].elem
+	path := func() Path { return child_path(f.sc.path(0), elem) }
+	if act := tr.count_node(f.ctx, path); act != limit_ok {
+		return act
+	}
+	nval, act := tr.limit_bytes(f.ctx, f.val, path)
+	if act == limit_cut {
+		if !f.asMap {
+			f.field().Set(nval)
+		}
+		f.val, act = nval, limit_ok
+	}
+	return act
+}
+
+// 输出为map时写入字段
+func (f *structFrame) admit(key, val reflect.Value) {
+	if !f.asMap {
+		return
+	}
+	elem := f.fields[f.i].elem
+	if f.kt.admit(f.ctx, f.tr, f.outmap, key, f.renamed, func() Path { return child_path(f.sc.path(0), elem) }) {
+		f.outmap.SetMapIndex(key, val)
+	}
+}
+
+// 当前字段，非公有字段只在拷贝中遍历，通过地址取得
+func (f *structFrame) field() reflect.Value {
+	return copied_field(f.inval.Field(f.fields[f.i].index))
+}
+
+func (f *structFrame) advance() {
+	f.i++
+	f.phase = member_begin
+}
+
+func (f *structFrame) result() interface{} {
+	tr := f.tr
+	var cn *containerNode
+	if !f.sc.inlined {
+		c := containerNode{kind: reflect.Struct, typ: f.intyp, sc: f.sc, fields: f.fields}
+		if f.asMap {
+			c.kind, c.key, c.value = reflect.Map, treeVariable{slot: stringType}, treeVariable{slot: interfaceType}
+		}
+		cn = tr.visit_container(f.ctx, c)
+	}
+
+	if f.asMap {
+		for _, node := range f.inserted {
+			f.outmap = tr.insert_pairs(f.outmap, node.nKey.(*treeVariable), node.nValue.(*treeVariable), node.inserts, true)
+			node.release()
+		}
+		if cn != nil {
+			f.outmap = tr.insert_pairs(f.outmap, &cn.key, &cn.value, cn.inserts, true)
 		}
-		// in = tr.walk(ctx, inval.Elem().Interface())
+		return f.outmap.Interface()
 	}
 
-	return in
+	if cn != nil {
+		for _, set := range cn.sets {
+			f.inval.Field(set.index).Set(set.val)
+		}
+	}
+
+	if !f.writable {
+		return f.inval.Interface()
+	}
+	return f.in
+}
+
+func (f *structFrame) release() {
+	*f = structFrame{}
+	structFramePool.Put(f)
+}
+
+// 设置了WithStructAsMap，或jsonable且设置了key转换时，struct输出为map
+func (tr *walker) struct_as_map() bool {
+	return tr.structAsMap || (tr.jsonable && tr.keyTransform != nil)
+}
+
+func indirect_type(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Pointer {
+		return t.Elem()
+	}
+	return t
 }
 
 func (tr *walker) is_literal(val *reflect.Value) bool {
-	if val == nil {
+	if val == nil || !val.IsValid() {
 		return true
 	}
-	switch val.Kind() {
-	case reflect.Array, reflect.Slice, reflect.Map, reflect.Struct, reflect.Ptr:
-		return false
+	// 设置了intercept时每个值都要经过它
+	return tr.intercept == nil && tr.plan(val.Type()).literal
+}
+
+// 子值遍历的结果，nil作为interface{}的零值，以便写入容器
+func walked_value(out interface{}) reflect.Value {
+	if out == nil {
+		return reflect.Zero(interfaceType)
 	}
-	return true
+	return reflect.ValueOf(out)
 }
 
+// 只解开interface，指针保留，以便原地修改指向的值
 func (tr *walker) unpack_value(val reflect.Value) reflect.Value {
-	if (val.Type().Kind() == reflect.Pointer || val.Type().Kind() == reflect.Interface) && !val.IsNil() {
+	if val.Type().Kind() == reflect.Interface && !val.IsNil() {
 		return val.Elem()
 	}
 	return val
 }
 
-func (tr *walker) dive(ctx context.Context) (context.Context, bool) {
-	if tr.maxDepth == NoDepthLimit {
-		return ctx, false
+func (tr *walker) is_leaf_type(t reflect.Type) bool {
+	if len(tr.leafTypes) == 0 && len(tr.leafIfaces) == 0 {
+		return false
+	}
+	if t.Kind() == reflect.Pointer {
+		return tr.is_leaf_type(t.Elem())
+	}
+	if _, ok := tr.leafTypes[t]; ok {
+		return true
+	}
+	if t.Kind() == reflect.Interface {
+		return false
+	}
+	for _, iface := range tr.leafIfaces {
+		if t.Implements(iface) || reflect.PointerTo(t).Implements(iface) {
+			return true
+		}
 	}
+	return false
+}
 
-	depth := ctx.Value(DepthCtxKey)
-	if depth == nil {
-		return context.WithValue(ctx, DepthCtxKey, new(int)), false
+// 容器位于interface{}类型的位置时，才允许放宽其元素类型
+func (tr *walker) is_loose(sc *scope) bool {
+	if tr.struct_as_map() {
+		// 输出为通用结构，所有容器都可以放宽
+		return true
+	}
+	if !tr.looseOverride {
+		return false
 	}
+	return !sc.strict
+}
 
-	if n, ok := depth.(*int); ok {
-		curDepth := *n
-		if curDepth < tr.maxDepth {
-			*n = curDepth + 1
-		} else {
-			return ctx, true
-		}
+// 取出拷贝中的字段，非公有字段不能调用Interface，通过地址重新取得
+// 只用于已经拷贝出的struct，或只遍历公有字段时的原地修改，不会修改输入的非公有字段
+func copied_field(v reflect.Value) reflect.Value {
+	if v.CanInterface() {
+		return v
 	}
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+}
 
-	return ctx, false
+// 进入子容器前记录其所在位置的声明类型
+func (tr *walker) enter_slot(sc *scope, slot reflect.Type) *scope {
+	if !tr.looseOverride || tr.struct_as_map() {
+		return sc
+	}
+	strict := slot.Kind() != reflect.Interface
+	if sc.strict == strict {
+		return sc
+	}
+	ns := *sc
+	ns.strict = strict
+	return &ns
+}
+
+// 将slice的元素类型放宽为interface{}
+func widen_slice(s reflect.Value) reflect.Value {
+	ns := make_slice(reflect.SliceOf(interfaceType), s.Len(), s.Cap())
+	for i := 0; i < s.Len(); i++ {
+		ns.Index(i).Set(s.Index(i))
+	}
+	return ns
 }
 
-func (tr *walker) rise(ctx context.Context) {
+// 将map中与key、value不兼容的类型放宽为interface{}
+func widen_map(m reflect.Value, key, val reflect.Type) reflect.Value {
+	kt, vt := m.Type().Key(), m.Type().Elem()
+	if !key.AssignableTo(kt) {
+		kt = interfaceType
+	}
+	if !val.AssignableTo(vt) {
+		vt = interfaceType
+	}
+
+	nm := reflect.MakeMapWithSize(reflect.MapOf(kt, vt), m.Len())
+	iter := m.MapRange()
+	for iter.Next() {
+		nm.SetMapIndex(iter.Key(), iter.Value())
+	}
+	return nm
+}
+
+// 进入下一层，超过最大深度时返回true
+func (tr *walker) dive(sc *scope) (*scope, bool) {
 	if tr.maxDepth == NoDepthLimit {
-		return
+		return sc, false
 	}
 
-	n, _ := ctx.Value(DepthCtxKey).(*int)
-	curDepth := *n
-	if curDepth > 0 {
-		*n = curDepth - 1
+	depth := sc.depth + 1
+	if depth > tr.maxDepth {
+		return sc, true
 	}
+	ns := *sc
+	ns.depth = depth
+	return &ns, false
 }
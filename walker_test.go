@@ -1,12 +1,21 @@
 package reflect_walker
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
+	"math/big"
+	"net/netip"
+	"net/url"
 	"reflect"
+	"runtime/debug"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func Test_Literal(t *testing.T) {
@@ -286,6 +295,20 @@ func Test_Slice(t *testing.T) {
 			}
 		})
 	}
+	// 遍历结果不写回WalkChildren返回的slice
+	input := pairList{{Key: "k", Value: []interface{}{"a"}}}
+	upper := func(ctx context.Context, node TreeNode) {
+		if s, err := node.Value().String(); err == nil {
+			node.Value().Set(strings.ToUpper(s))
+		}
+	}
+	got := NewTreeWalker(WithRoutine(upper)).Walk(context.Background(), input)
+	if expect := (pairList{{Key: "k", Value: []interface{}{"A"}}}); !reflect.DeepEqual(got, expect) {
+		t.Errorf("miss match: \n\texpect:%+v\n\tgot:   %+v", expect, got)
+	}
+	if expect := (pairList{{Key: "k", Value: []interface{}{"a"}}}); !reflect.DeepEqual(input, expect) {
+		t.Errorf("input modified: \n\texpect:%+v\n\tgot:   %+v", expect, input)
+	}
 }
 
 func Test_literal_routine(t *testing.T) {
@@ -339,6 +362,20 @@ func Test_literal_routine(t *testing.T) {
 			}
 		})
 	}
+	// 遍历结果不写回WalkChildren返回的slice
+	input := pairList{{Key: "k", Value: []interface{}{"a"}}}
+	upper := func(ctx context.Context, node TreeNode) {
+		if s, err := node.Value().String(); err == nil {
+			node.Value().Set(strings.ToUpper(s))
+		}
+	}
+	got := NewTreeWalker(WithRoutine(upper)).Walk(context.Background(), input)
+	if expect := (pairList{{Key: "k", Value: []interface{}{"A"}}}); !reflect.DeepEqual(got, expect) {
+		t.Errorf("miss match: \n\texpect:%+v\n\tgot:   %+v", expect, got)
+	}
+	if expect := (pairList{{Key: "k", Value: []interface{}{"a"}}}); !reflect.DeepEqual(input, expect) {
+		t.Errorf("input modified: \n\texpect:%+v\n\tgot:   %+v", expect, input)
+	}
 }
 
 func Test_map_routine(t *testing.T) {
@@ -918,220 +955,1660 @@ func Test_struct(t *testing.T) {
 	}
 }
 
-// 最大递归深度测试
-func Test_MaxDepth(t *testing.T) {
+// Set类型检查与转换测试
+func Test_Set_typecheck(t *testing.T) {
+	type Name string
+	type conf struct {
+		Port  int32
+		Level int8
+		Name  Name
+		Count int
+	}
+
+	testCases := []struct {
+		name   string
+		input  interface{}
+		loose  bool
+		set    map[string]interface{}
+		expect interface{}
+		errs   map[string]error
+	}{
+		{
+			name:   "struct字段自动转换",
+			input:  &conf{},
+			set:    map[string]interface{}{"Port": 5, "Name": "alice"},
+			expect: &conf{Port: 5, Name: "alice"},
+		},
+		{
+			name:   "struct字段类型不兼容",
+			input:  &conf{Count: 1},
+			set:    map[string]interface{}{"Count": "two"},
+			expect: &conf{Count: 1},
+			errs:   map[string]error{"Count": ErrTypeMismatch},
+		},
+		{
+			name:   "struct字段溢出",
+			input:  &conf{Level: 1},
+			set:    map[string]interface{}{"Level": 300},
+			expect: &conf{Level: 1},
+			errs:   map[string]error{"Level": ErrOverflow},
+		},
+		{
+			name:   "struct字段在宽松模式下仍做类型检查",
+			input:  &conf{Count: 1},
+			loose:  true,
+			set:    map[string]interface{}{"Count": "two"},
+			expect: &conf{Count: 1},
+			errs:   map[string]error{"Count": ErrTypeMismatch},
+		},
+		{
+			name:   "map值类型不兼容",
+			input:  map[string]int{"a": 1, "b": 2},
+			set:    map[string]interface{}{"a": "redacted"},
+			expect: map[string]int{"a": 1, "b": 2},
+			errs:   map[string]error{"a": ErrTypeMismatch},
+		},
+		{
+			name:   "map值在宽松模式下放宽类型",
+			input:  map[string]int{"a": 1, "b": 2},
+			loose:  true,
+			set:    map[string]interface{}{"a": "redacted"},
+			expect: map[string]interface{}{"a": "redacted", "b": 2},
+		},
+		{
+			name:   "map值转换数值宽度",
+			input:  map[string]int64{"a": 1},
+			set:    map[string]interface{}{"a": 7},
+			expect: map[string]int64{"a": 7},
+		},
+		{
+			name:   "map值string转[]byte",
+			input:  map[string][]byte{"a": nil},
+			set:    map[string]interface{}{"a": "abc"},
+			expect: map[string][]byte{"a": []byte("abc")},
+		},
+		{
+			name:   "嵌套在具体类型位置的map不放宽",
+			input:  map[string]map[string]int{"m": {"a": 1}},
+			loose:  true,
+			set:    map[string]interface{}{"a": "x"},
+			expect: map[string]map[string]int{"m": {"a": 1}},
+			errs:   map[string]error{"a": ErrTypeMismatch},
+		},
+	}
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			errs := map[string]error{}
+			opts := []WalkOption{
+				WithRoutine(func(ctx context.Context, node TreeNode) {
+					if node.Type() != NodeType_struct_member && node.Type() != NodeType_map_pair {
+						return
+					}
+					k := node.Key().MustString()
+					if nv, ok := v.set[k]; ok {
+						if err := node.Value().Set(nv); err != nil {
+							errs[k] = err
+						}
+					}
+				}),
+			}
+			if v.loose {
+				opts = append(opts, WithLooseOverride())
+			}
+
+			got := NewTreeWalker(opts...).Walk(context.Background(), v.input)
+			if !reflect.DeepEqual(got, v.expect) {
+				t.Errorf("miss match: \n\tinput:  %+v\n\texpect:%+v\n\tgot:   %+v", v.input, v.expect, got)
+			}
+			if len(errs) != len(v.errs) {
+				t.Errorf("error miss match: \n\texpect:%v\n\tgot:   %v", v.errs, errs)
+			}
+			for k, e := range v.errs {
+				if !errors.Is(errs[k], e) {
+					t.Errorf("error miss match on %s: \n\texpect:%v\n\tgot:   %v", k, e, errs[k])
+				}
+			}
+		})
+	}
+}
+
+// []byte整体作为字面量、复数访问测试
+func Test_Bytes_complex(t *testing.T) {
+	type payload struct {
+		Raw  []byte
+		Json json.RawMessage
+		Name string
+	}
+
 	testCases := []struct {
 		name     string
 		input    interface{}
-		jsonable bool
 		routines []Node_routine
 		expect   interface{}
-		maxDepth int
+		calls    int
 	}{
 		{
-			name:     "不限制深度测试",
-			maxDepth: NoDepthLimit,
-			input: map[interface{}]interface{}{
-				"a": map[interface{}]interface{}{
-					"b": map[interface{}]interface{}{
-						"c": map[interface{}]interface{}{
-							"d": map[interface{}]interface{}{
-								"e": map[interface{}]interface{}{
-									"f": map[interface{}]interface{}{
-										"g": []interface{}{
-											"alice",
-											"bob",
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			},
-			expect: map[string]interface{}{
-				"A": map[string]interface{}{
-					"B": map[string]interface{}{
-						"C": map[string]interface{}{
-							"D": map[string]interface{}{
-								"E": map[string]interface{}{
-									"F": map[string]interface{}{
-										"G": []interface{}{
-											"ALICE",
-											"BOB",
-										},
-									},
-								},
-							},
-						},
-					},
+			name:   "[]byte作为单个节点",
+			input:  []byte("secret"),
+			expect: []byte("******"),
+			calls:  1,
+			routines: []Node_routine{
+				func(ctx context.Context, node TreeNode) {
+					b := node.Value().MustBytes()
+					node.Value().Set(bytes.Repeat([]byte("*"), len(b)))
 				},
 			},
+		},
+		{
+			name:   "struct中的[]byte与json.RawMessage",
+			input:  &payload{Raw: []byte("secret"), Json: json.RawMessage(`{"a":1}`), Name: "alice"},
+			expect: &payload{Raw: []byte("<redacted>"), Json: json.RawMessage(`{}`), Name: "alice"},
+			calls:  3,
 			routines: []Node_routine{
 				func(ctx context.Context, node TreeNode) {
-					if node.Type() == NodeType_map_pair {
-						s := strings.ToUpper(node.Key().MustString())
-						node.Key().Set(s)
-					} else if node.Type() == NodeType_slice_member {
-						s, err := node.Value().String()
-						if err != nil {
-							return
+					switch node.Key().MustString() {
+					case "Raw":
+						node.Value().Set("<redacted>")
+					case "Json":
+						if _, err := node.Value().Bytes(); err != nil {
+							t.Errorf("bytes: %v", err)
 						}
-						s = strings.ToUpper(s)
-						node.Value().Set(s)
+						node.Value().Set(json.RawMessage(`{}`))
 					}
 				},
 			},
 		},
 		{
-			name:     "深度限制大于实际深度",
-			maxDepth: 10,
-			input: map[interface{}]interface{}{
-				"a": map[interface{}]interface{}{
-					"b": map[interface{}]interface{}{
-						"c": map[interface{}]interface{}{
-							"d": map[interface{}]interface{}{
-								"e": map[interface{}]interface{}{
-									"f": map[interface{}]interface{}{
-										"g": []interface{}{
-											"alice",
-											"bob",
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			},
-			expect: map[string]interface{}{
-				"A": map[string]interface{}{
-					"B": map[string]interface{}{
-						"C": map[string]interface{}{
-							"D": map[string]interface{}{
-								"E": map[string]interface{}{
-									"F": map[string]interface{}{
-										"G": []interface{}{
-											"ALICE",
-											"BOB",
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			},
+			name:   "slice中的[]byte",
+			input:  []interface{}{[]byte("ab"), "c"},
+			expect: []interface{}{[]byte("AB"), "c"},
+			calls:  2,
 			routines: []Node_routine{
 				func(ctx context.Context, node TreeNode) {
-					if node.Type() == NodeType_map_pair {
-						s := strings.ToUpper(node.Key().MustString())
-						node.Key().Set(s)
-					} else if node.Type() == NodeType_slice_member {
-						s, err := node.Value().String()
-						if err != nil {
-							return
-						}
-						s = strings.ToUpper(s)
-						node.Value().Set(s)
+					if b, err := node.Value().Bytes(); err == nil {
+						node.Value().Set(bytes.ToUpper(b))
 					}
 				},
 			},
 		},
 		{
-			name:     "深度限制小于实际深度",
-			maxDepth: 5,
-			input: map[interface{}]interface{}{
-				"a": map[interface{}]interface{}{
-					"b": map[interface{}]interface{}{
-						"c": map[interface{}]interface{}{
-							"d": map[interface{}]interface{}{
-								"e": map[interface{}]interface{}{
-									"f": map[interface{}]interface{}{
-										"g": []interface{}{
-											"alice",
-											"bob",
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			},
-			expect: map[string]interface{}{ // 0
-				"A": map[string]interface{}{ // 1
-					"B": map[string]interface{}{ // 2
-						"C": map[string]interface{}{ // 3
-							"D": map[string]interface{}{ // 4
-								"E": map[string]interface{}{ // 5
-									"F": map[interface{}]interface{}{
-										"g": []interface{}{
-											"alice",
-											"bob",
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			},
+			name:   "复数",
+			input:  []interface{}{complex64(1 + 2i), complex128(3 + 4i)},
+			expect: []interface{}{complex64(2 + 4i), complex128(6 + 8i)},
+			calls:  2,
 			routines: []Node_routine{
 				func(ctx context.Context, node TreeNode) {
-					if node.Type() == NodeType_map_pair {
-						s := strings.ToUpper(node.Key().MustString())
-						node.Key().Set(s)
-					} else if node.Type() == NodeType_slice_member {
-						s, err := node.Value().String()
-						if err != nil {
-							return
-						}
-						s = strings.ToUpper(s)
-						node.Value().Set(s)
+					switch node.Value().TypeKind() {
+					case reflect.Complex64:
+						node.Value().Set(node.Value().MustComplex64() * 2)
+					case reflect.Complex128:
+						c, _ := node.Value().Complex128()
+						node.Value().Set(c * 2)
 					}
 				},
 			},
 		},
+	}
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			calls := 0
+			routines := append([]Node_routine{
+				func(ctx context.Context, node TreeNode) { calls++ },
+			}, v.routines...)
+
+			got := NewTreeWalker(WithRoutine(routines...)).Walk(context.Background(), v.input)
+			if !reflect.DeepEqual(got, v.expect) {
+				t.Errorf("miss match: \n\tinput:  %+v\n\texpect:%+v\n\tgot:   %+v", v.input, v.expect, got)
+			}
+			if calls != v.calls {
+				t.Errorf("routine calls miss match: expect %d, got %d", v.calls, calls)
+			}
+		})
+	}
+}
+
+// 叶子类型注册测试
+func Test_LeafTypes(t *testing.T) {
+	type event struct {
+		Name    string
+		At      time.Time
+		Amount  *big.Int
+		Addr    netip.Addr
+		Updated *time.Time
+	}
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	later := at.Add(time.Hour)
+
+	testCases := []struct {
+		name    string
+		input   interface{}
+		options []WalkOption
+		expect  interface{}
+		keys    []string
+	}{
 		{
-			name:     "深度限制低于实际深度(两组map路径)",
-			maxDepth: 5,
-			input: map[interface{}]interface{}{ // 0
-				"a": map[interface{}]interface{}{ // 1
-					"b": map[interface{}]interface{}{ // 2
-						"c": map[interface{}]interface{}{ // 3
-							"d": map[interface{}]interface{}{ // 4
-								"e": map[interface{}]interface{}{
-									"f": map[interface{}]interface{}{
-										"g": []interface{}{
-											"alice",
-											"bob",
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-				"b": map[interface{}]interface{}{
-					"c": map[interface{}]interface{}{
-						"d": map[interface{}]interface{}{
-							"e": map[interface{}]interface{}{
-								"f": map[interface{}]interface{}{
-									"g": []interface{}{
-										"alice",
-										"bob",
-									},
-								},
-							},
-						},
-					},
-				},
-			},
-			expect: map[string]interface{}{ // 0
-				"A": map[string]interface{}{ // 1
-					"B": map[string]interface{}{ // 2
-						"C": map[string]interface{}{ // 3
+			name:    "注册类型作为叶子",
+			input:   &event{Name: "a", At: at, Amount: big.NewInt(5), Updated: &at},
+			options: []WalkOption{WithLeafTypes(time.Time{}, big.Int{}, netip.Addr{})},
+			expect:  &event{Name: "a", At: later, Amount: big.NewInt(10), Updated: &later},
+			keys:    []string{"Name", "At", "Amount", "Addr", "Updated"},
+		},
+		{
+			name:    "TextMarshaler作为叶子",
+			input:   &event{Name: "a", At: at, Amount: big.NewInt(5), Updated: &at},
+			options: []WalkOption{WithTextMarshalerLeaves()},
+			expect:  &event{Name: "a", At: later, Amount: big.NewInt(10), Updated: &later},
+			keys:    []string{"Name", "At", "Amount", "Addr", "Updated"},
+		},
+		{
+			name:    "Stringer作为叶子",
+			input:   &event{Name: "a", At: at, Amount: big.NewInt(5), Updated: &at},
+			options: []WalkOption{WithStringerLeaves()},
+			expect:  &event{Name: "a", At: later, Amount: big.NewInt(10), Updated: &later},
+			keys:    []string{"Name", "At", "Amount", "Addr", "Updated"},
+		},
+		{
+			name:    "url.URL作为叶子",
+			input:   []url.URL{{Host: "a"}},
+			options: []WalkOption{WithStringerLeaves()},
+			expect:  []url.URL{{Host: "a"}},
+		},
+		{
+			name:    "slice中的叶子",
+			input:   []time.Time{at},
+			options: []WalkOption{WithLeafTypes(time.Time{})},
+			expect:  []time.Time{later},
+		},
+		{
+			name:    "顶层叶子指针",
+			input:   &at,
+			options: []WalkOption{WithLeafTypes(time.Time{})},
+			expect:  &later,
+		},
+	}
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			var keys []string
+			routine := func(ctx context.Context, node TreeNode) {
+				if node.Type() == NodeType_struct_member {
+					keys = append(keys, node.Key().MustString())
+				}
+				switch val := node.Value().Interface().(type) {
+				case time.Time:
+					node.Value().Set(val.Add(time.Hour))
+				case *time.Time:
+					nt := val.Add(time.Hour)
+					node.Value().Set(&nt)
+				case *big.Int:
+					node.Value().Set(new(big.Int).Mul(val, big.NewInt(2)))
+				}
+			}
+			// 顶层指针会原地修改，使用副本
+			input := v.input
+			if p, ok := input.(*time.Time); ok {
+				cp := *p
+				input = &cp
+			}
+
+			opts := append([]WalkOption{WithRoutine(routine)}, v.options...)
+			got := NewTreeWalker(opts...).Walk(context.Background(), input)
+			if !reflect.DeepEqual(got, v.expect) {
+				t.Errorf("miss match: \n\tinput:  %+v\n\texpect:%+v\n\tgot:   %+v", v.input, v.expect, got)
+			}
+			if v.keys != nil && !reflect.DeepEqual(keys, v.keys) {
+				t.Errorf("keys miss match: \n\texpect:%v\n\tgot:   %v", v.keys, keys)
+			}
+		})
+	}
+}
+
+// 有序map，数据存放在非导出字段中
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newOrderedMap(kv ...interface{}) *orderedMap {
+	om := &orderedMap{values: map[string]interface{}{}}
+	for i := 0; i+1 < len(kv); i += 2 {
+		om.keys = append(om.keys, kv[i].(string))
+		om.values[kv[i].(string)] = kv[i+1]
+	}
+	return om
+}
+
+func (om *orderedMap) WalkChildren() []WalkPair {
+	pairs := make([]WalkPair, 0, len(om.keys))
+	for _, k := range om.keys {
+		pairs = append(pairs, WalkPair{Key: k, Value: om.values[k]})
+	}
+	return pairs
+}
+
+func (om *orderedMap) WalkRebuild(children []WalkPair) interface{} {
+	nm := newOrderedMap()
+	for _, c := range children {
+		nm.keys = append(nm.keys, c.Key.(string))
+		nm.values[c.Key.(string)] = c.Value
+	}
+	return nm
+}
+
+// 子节点直接存放在slice中，WalkChildren返回内部存储
+type pairList []WalkPair
+
+func (pl pairList) WalkChildren() []WalkPair { return pl }
+
+func (pl pairList) WalkRebuild(children []WalkPair) interface{} { return pairList(children) }
+
+// 只有指针实现了Walkable
+type counter struct {
+	items []int
+}
+
+func (c *counter) WalkChildren() []WalkPair {
+	pairs := make([]WalkPair, len(c.items))
+	for i, n := range c.items {
+		pairs[i] = WalkPair{Key: i, Value: n}
+	}
+	return pairs
+}
+
+func (c *counter) WalkRebuild(children []WalkPair) interface{} {
+	nc := &counter{}
+	for _, p := range children {
+		nc.items = append(nc.items, p.Value.(int))
+	}
+	return nc
+}
+
+// 自定义容器测试
+func Test_Walkable(t *testing.T) {
+	type holder struct {
+		C counter
+	}
+
+	testCases := []struct {
+		name     string
+		input    interface{}
+		routines []Node_routine
+		expect   interface{}
+	}{
+		{
+			name:   "修改与删除子节点",
+			input:  newOrderedMap("b", 1, "a", "x", "c", 3),
+			expect: newOrderedMap("B", 2, "A", "x"),
+			routines: []Node_routine{
+				func(ctx context.Context, node TreeNode) {
+					if node.Type() != NodeType_map_pair {
+						return
+					}
+					k := node.Key().MustString()
+					if k == "c" {
+						node.Delete()
+						return
+					}
+					node.Key().Set(strings.ToUpper(k))
+					if n, err := node.Value().Int(); err == nil {
+						node.Value().Set(n * 2)
+					}
+				},
+			},
+		},
+		{
+			name: "嵌套在map及slice中",
+			input: map[string]interface{}{
+				"om": newOrderedMap("list", []interface{}{"a", "b"}, "n", 1),
+			},
+			expect: map[string]interface{}{
+				"om": newOrderedMap("list", []interface{}{"A", "B"}, "n", 1),
+			},
+			routines: []Node_routine{
+				func(ctx context.Context, node TreeNode) {
+					if s, err := node.Value().String(); err == nil {
+						node.Value().Set(strings.ToUpper(s))
+					}
+				},
+			},
+		},
+		{
+			name:   "nil容器",
+			input:  (*orderedMap)(nil),
+			expect: (*orderedMap)(nil),
+		},
+		{
+			name:   "指针实现Walkable的值字段",
+			input:  &holder{C: counter{items: []int{1, 2}}},
+			expect: &holder{C: counter{items: []int{2, 4}}},
+			routines: []Node_routine{
+				func(ctx context.Context, node TreeNode) {
+					if n, err := node.Value().Int(); err == nil {
+						node.Value().Set(int(n * 2))
+					}
+				},
+			},
+		},
+	}
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			tw := NewTreeWalker(
+				WithRoutine(v.routines...),
+			)
+			got := tw.Walk(context.Background(), v.input)
+			if !reflect.DeepEqual(got, v.expect) {
+				t.Errorf("miss match: \n\tinput:  %+v\n\texpect:%+v\n\tgot:   %+v", v.input, v.expect, got)
+			}
+		})
+	}
+	// 遍历结果不写回WalkChildren返回的slice
+	input := pairList{{Key: "k", Value: []interface{}{"a"}}}
+	upper := func(ctx context.Context, node TreeNode) {
+		if s, err := node.Value().String(); err == nil {
+			node.Value().Set(strings.ToUpper(s))
+		}
+	}
+	got := NewTreeWalker(WithRoutine(upper)).Walk(context.Background(), input)
+	if expect := (pairList{{Key: "k", Value: []interface{}{"A"}}}); !reflect.DeepEqual(got, expect) {
+		t.Errorf("miss match: \n\texpect:%+v\n\tgot:   %+v", expect, got)
+	}
+	if expect := (pairList{{Key: "k", Value: []interface{}{"a"}}}); !reflect.DeepEqual(input, expect) {
+		t.Errorf("input modified: \n\texpect:%+v\n\tgot:   %+v", expect, input)
+	}
+}
+
+// interface类型的位置测试
+func Test_Interface(t *testing.T) {
+	type inner struct {
+		Name string
+	}
+	type outer struct {
+		Any  interface{}
+		List []interface{}
+	}
+
+	m := map[string]interface{}{"a": "x"}
+
+	testCases := []struct {
+		name   string
+		input  interface{}
+		expect interface{}
+	}{
+		{
+			name:   "interface字段中的map",
+			input:  &outer{Any: map[string]interface{}{"a": "x"}},
+			expect: &outer{Any: map[string]interface{}{"a": "X"}},
+		},
+		{
+			name:   "interface字段中的struct值",
+			input:  &outer{Any: inner{Name: "x"}},
+			expect: &outer{Any: inner{Name: "X"}},
+		},
+		{
+			name:   "interface字段中的struct指针",
+			input:  &outer{Any: &inner{Name: "x"}},
+			expect: &outer{Any: &inner{Name: "X"}},
+		},
+		{
+			name:   "interface字段中的字面量",
+			input:  &outer{Any: "x"},
+			expect: &outer{Any: "X"},
+		},
+		{
+			name:   "slice中的struct值",
+			input:  &outer{List: []interface{}{inner{Name: "x"}, "y"}},
+			expect: &outer{List: []interface{}{inner{Name: "X"}, "Y"}},
+		},
+		{
+			name:   "map中的struct值",
+			input:  map[string]interface{}{"k": inner{Name: "x"}},
+			expect: map[string]interface{}{"k": inner{Name: "X"}},
+		},
+		{
+			name:   "顶层struct值返回修改后的拷贝",
+			input:  inner{Name: "x"},
+			expect: inner{Name: "X"},
+		},
+		{
+			name:   "map指针",
+			input:  &m,
+			expect: &map[string]interface{}{"a": "X"},
+		},
+	}
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			tw := NewTreeWalker(
+				WithRoutine(func(ctx context.Context, node TreeNode) {
+					if node.Value().TypeKind() == reflect.String {
+						node.Value().Set(strings.ToUpper(node.Value().MustString()))
+					}
+				}),
+			)
+			got := tw.Walk(context.Background(), v.input)
+			if !reflect.DeepEqual(got, v.expect) {
+				t.Errorf("miss match: \n\tinput:  %+v\n\texpect:%+v\n\tgot:   %+v", v.input, v.expect, got)
+			}
+		})
+	}
+}
+
+// 相互引用的类型，检查cycleA时cycleB遇到环
+type cycleA struct {
+	B *cycleB
+	Y int `default:"5"`
+}
+
+type cycleB struct {
+	A *cycleA
+}
+
+// 默认值填充测试
+func Test_Defaults(t *testing.T) {
+	type dbConf struct {
+		Host    string        `default:"localhost"`
+		Port    int           `default:"5432"`
+		Timeout time.Duration `default:"3s"`
+	}
+	type chain struct {
+		Next  *chain
+		Value int `default:"1"`
+	}
+	type conf struct {
+		Name   string         `default:"app"`
+		Debug  *bool          `default:"true"`
+		Tags   []string       `default:"a,b"`
+		Labels map[string]int `default:"x:1,y:2"`
+		Keep   int            `default:"7"`
+		Owner  string
+		DB     *dbConf
+		Chain  *chain
+	}
+	type cycles struct {
+		A *cycleA
+		B *cycleB
+	}
+	type bad struct {
+		Name string `default:"x"`
+		Port int    `default:"abc"`
+	}
+	yes := true
+
+	testCases := []struct {
+		name      string
+		input     interface{}
+		providers []DefaultsProvider
+		opts      []WalkOption
+		expect    interface{}
+		defaulted []string
+		err       error
+	}{
+		{
+			name:  "标签默认值",
+			input: &conf{Keep: 9},
+			opts:  []WalkOption{WithDefaultPointers()},
+			expect: &conf{
+				Name:   "app",
+				Debug:  &yes,
+				Tags:   []string{"a", "b"},
+				Labels: map[string]int{"x": 1, "y": 2},
+				Keep:   9,
+				DB:     &dbConf{Host: "localhost", Port: 5432, Timeout: 3 * time.Second},
+				Chain:  &chain{Value: 1},
+			},
+			defaulted: []string{"Name", "Host", "Port", "Timeout", "Value"},
+		},
+		{
+			name:  "默认值提供者优先",
+			input: &conf{Name: "svc", DB: &dbConf{Host: "db"}},
+			opts:  []WalkOption{WithDefaultPointers()},
+			providers: []DefaultsProvider{
+				func(owner reflect.Type, field reflect.StructField) (interface{}, bool) {
+					switch field.Name {
+					case "Owner":
+						return "ops", true
+					case "Port":
+						return 3306, true
+					}
+					return nil, false
+				},
+			},
+			expect: &conf{
+				Name:   "svc",
+				Debug:  &yes,
+				Tags:   []string{"a", "b"},
+				Labels: map[string]int{"x": 1, "y": 2},
+				Keep:   7,
+				Owner:  "ops",
+				DB:     &dbConf{Host: "db", Port: 3306, Timeout: 3 * time.Second},
+				Chain:  &chain{Value: 1},
+			},
+			defaulted: []string{"Keep", "Owner", "Port", "Timeout", "Value"},
+		},
+		{
+			name:  "nil指针默认保持nil",
+			input: &conf{},
+			expect: &conf{
+				Name:   "app",
+				Debug:  &yes,
+				Tags:   []string{"a", "b"},
+				Labels: map[string]int{"x": 1, "y": 2},
+				Keep:   7,
+			},
+			defaulted: []string{"Name", "Keep"},
+		},
+		{
+			name:  "相互引用的类型",
+			input: &cycles{},
+			opts:  []WalkOption{WithDefaultPointers()},
+			expect: &cycles{
+				A: &cycleA{B: &cycleB{}, Y: 5},
+				B: &cycleB{A: &cycleA{Y: 5}},
+			},
+			defaulted: []string{"Y", "Y"},
+		},
+		{
+			name:      "无法解析的默认值标签",
+			input:     &bad{},
+			expect:    &bad{Name: "x"},
+			defaulted: []string{"Name"},
+			err:       ErrParseFailed,
+		},
+		{
+			name:  "提供者的值类型不符",
+			input: &dbConf{},
+			providers: []DefaultsProvider{
+				func(owner reflect.Type, field reflect.StructField) (interface{}, bool) {
+					return []int{1}, field.Name == "Port"
+				},
+			},
+			expect:    &dbConf{Host: "localhost", Timeout: 3 * time.Second},
+			defaulted: []string{"Host", "Timeout"},
+			err:       ErrTypeMismatch,
+		},
+	}
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			var defaulted []string
+			tw := NewTreeWalker(append([]WalkOption{
+				WithDefaults(v.providers...),
+				WithRoutine(func(ctx context.Context, node TreeNode) {
+					if node.Type() == NodeType_struct_member && node.Defaulted() {
+						defaulted = append(defaulted, node.Key().MustString())
+					}
+				}),
+			}, v.opts...)...)
+			got, err := tw.WalkWithError(context.Background(), v.input)
+			if !reflect.DeepEqual(got, v.expect) {
+				t.Errorf("miss match: \n\tinput:  %+v\n\texpect:%+v\n\tgot:   %+v", v.input, v.expect, got)
+			}
+			if !reflect.DeepEqual(defaulted, v.defaulted) {
+				t.Errorf("defaulted miss match: \n\texpect:%v\n\tgot:   %v", v.defaulted, defaulted)
+			}
+			var pe *PathError
+			if !errors.Is(err, v.err) || err != nil && !errors.As(err, &pe) {
+				t.Errorf("error miss match: \n\texpect:%v\n\tgot:   %v", v.err, err)
+			}
+		})
+	}
+}
+
+// 节点路径及容器节点测试
+func Test_Path(t *testing.T) {
+	type replica struct {
+		Host string `json:"host"`
+	}
+	type db struct {
+		Replicas []replica
+		Opts     map[string]interface{}
+	}
+
+	testCases := []struct {
+		name       string
+		input      interface{}
+		containers bool
+		expect     []string
+	}{
+		{
+			name: "叶子节点路径",
+			input: &db{
+				Replicas: []replica{{Host: "a"}},
+				Opts:     map[string]interface{}{"tags": []interface{}{"x"}},
+			},
+			expect: []string{"Replicas.0.Host", "Opts.tags.0", "Opts.tags"},
+		},
+		{
+			name: "容器节点路径",
+			input: &db{
+				Replicas: []replica{{Host: "a"}},
+			},
+			containers: true,
+			expect:     []string{"Replicas.0.Host", "Replicas.0", "Replicas", "Opts"},
+		},
+		{
+			name:   "顶层字面量",
+			input:  "x",
+			expect: []string{""},
+		},
+	}
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			var paths []string
+			opts := []WalkOption{
+				WithRoutine(func(ctx context.Context, node TreeNode) {
+					paths = append(paths, node.Path().String())
+					if node.Key() != nil && node.Key().MustString() == "Host" && node.Tag().Get("json") != "host" {
+						t.Errorf("tag miss match: %q", node.Tag())
+					}
+				}),
+			}
+			if v.containers {
+				opts = append(opts, WithContainerNodes())
+			}
+			NewTreeWalker(opts...).Walk(context.Background(), v.input)
+			if !reflect.DeepEqual(paths, v.expect) {
+				t.Errorf("paths miss match: \n\texpect:%v\n\tgot:   %v", v.expect, paths)
+			}
+		})
+	}
+}
+
+// key转换测试
+func Test_KeyTransform(t *testing.T) {
+	for in, expect := range map[string][3]string{
+		"UserName":     {"user_name", "userName", "user-name"},
+		"HTTPServerID": {"http_server_id", "httpServerId", "http-server-id"},
+		"user_name":    {"user_name", "userName", "user-name"},
+		"max-conn2":    {"max_conn2", "maxConn2", "max-conn2"},
+	} {
+		got := [3]string{SnakeCase(in), CamelCase(in), KebabCase(in)}
+		if got != expect {
+			t.Errorf("%s miss match: \n\texpect:%v\n\tgot:   %v", in, expect, got)
+		}
+	}
+
+	type Base struct {
+		CreatedBy string
+	}
+	type meta struct {
+		Region string
+		zone   string
+	}
+	type account struct {
+		Base
+		meta
+		UserName string
+		ID       int `json:"id"`
+		Secret   string
+		Skip     string `json:"-"`
+		Profile  *struct{ DisplayName string }
+	}
+
+	testCases := []struct {
+		name      string
+		input     interface{}
+		transform KeyTransform
+		jsonable  bool
+		routines  []Node_routine
+		expect    interface{}
+		err       error
+	}{
+		{
+			name:      "map key转换为snake_case",
+			input:     map[string]interface{}{"userName": 1, "HTTPServer": map[string]interface{}{"maxConn": 2}},
+			transform: SnakeCase,
+			expect:    map[string]interface{}{"user_name": 1, "http_server": map[string]interface{}{"max_conn": 2}},
+		},
+		{
+			name:      "map key转换为kebab-case",
+			input:     map[string]int{"userName": 1},
+			transform: KebabCase,
+			expect:    map[string]int{"user-name": 1},
+		},
+		{
+			name:      "自定义转换",
+			input:     map[string]int{"a": 1},
+			transform: strings.ToUpper,
+			expect:    map[string]int{"A": 1},
+		},
+		{
+			name:      "key冲突时保留未改名的key",
+			input:     map[string]int{"user_name": 1, "userName": 2},
+			transform: SnakeCase,
+			expect:    map[string]int{"user_name": 1},
+			err:       ErrKeyCollision,
+		},
+		{
+			name: "jsonable模式下struct转换为map",
+			input: &account{
+				Base:     Base{CreatedBy: "root"},
+				meta:     meta{Region: "eu", zone: "a"},
+				UserName: "alice",
+				ID:       7,
+				Secret:   "s",
+				Skip:     "x",
+				Profile:  &struct{ DisplayName string }{DisplayName: "Alice"},
+			},
+			transform: CamelCase,
+			jsonable:  true,
+			routines: []Node_routine{
+				func(ctx context.Context, node TreeNode) {
+					if node.Type() != NodeType_struct_member {
+						return
+					}
+					switch node.Key().MustString() {
+					case "secret":
+						node.Delete()
+					case "id":
+						node.Key().Set("accountId")
+					}
+				},
+			},
+			expect: map[string]interface{}{
+				"createdBy": "root",
+				"region":    "eu",
+				"userName":  "alice",
+				"accountId": 7,
+				"profile":   map[string]interface{}{"displayName": "Alice"},
+			},
+		},
+	}
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			opts := []WalkOption{
+				WithKeyTransform(v.transform),
+				WithRoutine(v.routines...),
+			}
+			if v.jsonable {
+				opts = append(opts, WithJsonableMap())
+			}
+			got, err := NewTreeWalker(opts...).WalkWithError(context.Background(), v.input)
+			if !reflect.DeepEqual(got, v.expect) {
+				t.Errorf("miss match: \n\tinput:  %+v\n\texpect:%+v\n\tgot:   %+v", v.input, v.expect, got)
+			}
+			if !errors.Is(err, v.err) {
+				t.Errorf("error miss match: \n\texpect:%v\n\tgot:   %v", v.err, err)
+			}
+		})
+	}
+}
+
+// 插入测试
+func Test_Insert(t *testing.T) {
+	type item struct {
+		Name  string
+		Price float64
+	}
+	type labeled struct {
+		Labels map[string]string
+		Tags   []string
+	}
+	type base struct {
+		ID int
+	}
+	type outer struct {
+		base
+		Name string
+	}
+
+	testCases := []struct {
+		name       string
+		input      interface{}
+		opts       []WalkOption
+		routines   []Node_routine
+		containers []Container_routine
+		expect     interface{}
+		err        error
+	}{
+		{
+			name:  "map中注入_version",
+			input: map[string]interface{}{"name": "a"},
+			routines: []Node_routine{
+				func(ctx context.Context, node TreeNode) {
+					if node.Type() == NodeType_map_pair && node.Key().MustString() == "name" {
+						node.Insert("_version", 2)
+					}
+				},
+			},
+			expect: map[string]interface{}{"name": "a", "_version": 2},
+		},
+		{
+			name:  "插入的key覆盖已有key",
+			input: map[string]int{"a": 1, "b": 2},
+			routines: []Node_routine{
+				func(ctx context.Context, node TreeNode) {
+					if node.Type() == NodeType_map_pair && node.Key().MustString() == "a" {
+						node.Insert("b", 3)
+					}
+				},
+			},
+			expect: map[string]int{"a": 1, "b": 3},
+		},
+		{
+			name:  "插入类型不符",
+			input: map[string]int{"a": 1},
+			routines: []Node_routine{
+				func(ctx context.Context, node TreeNode) {
+					if err := node.Insert("b", "x"); !errors.Is(err, ErrTypeMismatch) {
+						panic(err)
+					}
+				},
+			},
+			expect: map[string]int{"a": 1},
+		},
+		{
+			name:  "loose模式下插入不同类型",
+			input: map[string]int{"a": 1},
+			opts:  []WalkOption{WithLooseOverride()},
+			routines: []Node_routine{
+				func(ctx context.Context, node TreeNode) {
+					node.Insert("b", "x")
+				},
+			},
+			expect: map[string]interface{}{"a": 1, "b": "x"},
+		},
+		{
+			name:  "slice前后插入",
+			input: []int{1, 2, 3},
+			routines: []Node_routine{
+				func(ctx context.Context, node TreeNode) {
+					if node.Value().MustInt() == 2 {
+						node.InsertBefore(10, 11)
+						node.InsertAfter(20)
+					}
+				},
+			},
+			expect: []int{1, 10, 11, 2, 20, 3},
+		},
+		{
+			name:  "delete加insert相当于替换",
+			input: []string{"a", "b", "c"},
+			routines: []Node_routine{
+				func(ctx context.Context, node TreeNode) {
+					if node.Value().MustString() == "b" {
+						node.Delete()
+						node.InsertAfter("b1", "b2")
+					}
+				},
+			},
+			expect: []string{"a", "b1", "b2", "c"},
+		},
+		{
+			name:  "struct输出为map时新增字段",
+			input: item{Name: "apple", Price: 1.5},
+			opts:  []WalkOption{WithStructAsMap()},
+			routines: []Node_routine{
+				func(ctx context.Context, node TreeNode) {
+					if node.Type() == NodeType_struct_member && node.Key().MustString() == "Price" {
+						node.Insert("PriceCents", int(node.Value().MustFloat64()*100))
+					}
+				},
+			},
+			expect: map[string]interface{}{"Name": "apple", "Price": 1.5, "PriceCents": 150},
+		},
+		{
+			name:  "原地修改的struct不支持插入",
+			input: &item{Name: "apple"},
+			routines: []Node_routine{
+				func(ctx context.Context, node TreeNode) {
+					if err := node.Insert("x", 1); !errors.Is(err, ErrInsertUnsupported) {
+						panic(err)
+					}
+					if err := node.InsertAfter(1); !errors.Is(err, ErrInsertUnsupported) {
+						panic(err)
+					}
+					if err := node.InsertChild("x", 1); !errors.Is(err, ErrInsertUnsupported) {
+						panic(err)
+					}
+				},
+			},
+			expect: &item{Name: "apple"},
+		},
+		{
+			name:  "空map中注入_version",
+			input: map[string]interface{}{"meta": map[string]interface{}{}},
+			routines: []Node_routine{
+				func(ctx context.Context, node TreeNode) {
+					if node.Type() == NodeType_map_pair && node.Key().MustString() == "meta" {
+						node.InsertChild("_version", 2)
+					}
+				},
+			},
+			expect: map[string]interface{}{"meta": map[string]interface{}{"_version": 2}},
+		},
+		{
+			name:  "nil map中插入",
+			input: map[string]map[string]int{"a": nil},
+			routines: []Node_routine{
+				func(ctx context.Context, node TreeNode) {
+					if err := node.InsertChild("v", 1); err != nil {
+						panic(err)
+					}
+				},
+			},
+			expect: map[string]map[string]int{"a": {"v": 1}},
+		},
+		{
+			name:  "空struct输出为map时插入",
+			input: struct{ Meta struct{} }{},
+			opts:  []WalkOption{WithStructAsMap(), WithContainerNodes()},
+			routines: []Node_routine{
+				func(ctx context.Context, node TreeNode) {
+					if node.Type() == NodeType_struct_member && node.Key().MustString() == "Meta" {
+						node.InsertChild("_version", 2)
+					}
+				},
+			},
+			expect: map[string]interface{}{"Meta": map[string]interface{}{"_version": 2}},
+		},
+		{
+			name:  "插入子项类型不符",
+			input: map[string]map[string]int{"a": {}},
+			routines: []Node_routine{
+				func(ctx context.Context, node TreeNode) {
+					if err := node.InsertChild("v", "x"); !errors.Is(err, ErrTypeMismatch) {
+						panic(err)
+					}
+				},
+			},
+			expect: map[string]map[string]int{"a": {}},
+		},
+		{
+			name:  "Walkable插入",
+			input: newOrderedMap("a", 1),
+			routines: []Node_routine{
+				func(ctx context.Context, node TreeNode) {
+					node.Insert("b", 2)
+				},
+			},
+			expect: newOrderedMap("a", 1, "b", 2),
+		},
+		{
+			name:  "根容器注入_version",
+			input: map[string]interface{}{"name": "a", "meta": map[string]interface{}{}},
+			containers: []Container_routine{
+				func(ctx context.Context, c Container) {
+					if c.Depth() == 0 {
+						c.Insert("_version", 2)
+					}
+				},
+			},
+			expect: map[string]interface{}{"name": "a", "meta": map[string]interface{}{}, "_version": 2},
+		},
+		{
+			name:  "每个容器插入一次",
+			input: map[string]interface{}{"a": 1, "b": 2, "meta": map[string]interface{}{}},
+			containers: []Container_routine{
+				func(ctx context.Context, c Container) {
+					c.Insert("_path", c.Path().String())
+				},
+			},
+			expect: map[string]interface{}{"a": 1, "b": 2, "_path": "", "meta": map[string]interface{}{"_path": "meta"}},
+		},
+		{
+			name:  "nil的map与slice",
+			input: &labeled{},
+			containers: []Container_routine{
+				func(ctx context.Context, c Container) {
+					if err := c.Insert("env", "prod"); err != nil && c.Type().Kind() == reflect.Map {
+						panic(err)
+					}
+					if err := c.Append("x"); err != nil && c.Type().Kind() == reflect.Slice {
+						panic(err)
+					}
+				},
+			},
+			expect: &labeled{Labels: map[string]string{"env": "prod"}, Tags: []string{"x"}},
+		},
+		{
+			name:  "没有插入时nil保持不变",
+			input: labeled{},
+			containers: []Container_routine{
+				func(ctx context.Context, c Container) {},
+			},
+			expect: labeled{},
+		},
+		{
+			name:  "原样输出的struct设置字段",
+			input: item{Name: "apple"},
+			containers: []Container_routine{
+				func(ctx context.Context, c Container) {
+					if err := c.Insert("Price", 2); err != nil {
+						panic(err)
+					}
+					if err := c.Insert("Weight", 1); !errors.Is(err, ErrInsertUnsupported) {
+						panic(err)
+					}
+					if err := c.Insert("Name", 1); !errors.Is(err, ErrTypeMismatch) {
+						panic(err)
+					}
+				},
+			},
+			expect: item{Name: "apple", Price: 2},
+		},
+		{
+			name:  "struct输出为map时在容器上新增字段",
+			input: item{Name: "apple"},
+			opts:  []WalkOption{WithStructAsMap()},
+			containers: []Container_routine{
+				func(ctx context.Context, c Container) {
+					c.Insert("_version", 2)
+				},
+			},
+			expect: map[string]interface{}{"Name": "apple", "Price": 0.0, "_version": 2},
+		},
+		{
+			name:  "展开的嵌入struct不单独交出",
+			input: outer{base: base{ID: 1}, Name: "a"},
+			opts:  []WalkOption{WithStructAsMap()},
+			containers: []Container_routine{
+				func(ctx context.Context, c Container) {
+					c.Insert(c.Type().Name(), true)
+				},
+			},
+			expect: map[string]interface{}{"ID": 1, "Name": "a", "outer": true},
+		},
+		{
+			name:  "slice追加类型不符",
+			input: []int{1},
+			containers: []Container_routine{
+				func(ctx context.Context, c Container) {
+					if err := c.Append("x"); !errors.Is(err, ErrTypeMismatch) {
+						panic(err)
+					}
+					if err := c.Insert("x", 1); !errors.Is(err, ErrInsertUnsupported) {
+						panic(err)
+					}
+					c.Append(2)
+				},
+			},
+			expect: []int{1, 2},
+		},
+		{
+			name:  "Walkable容器插入",
+			input: newOrderedMap("a", 1),
+			containers: []Container_routine{
+				func(ctx context.Context, c Container) {
+					c.Insert("b", 2)
+				},
+			},
+			expect: newOrderedMap("a", 1, "b", 2),
+		},
+	}
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			opts := append([]WalkOption{WithRoutine(v.routines...), WithContainerRoutine(v.containers...)}, v.opts...)
+			got, err := NewTreeWalker(opts...).WalkWithError(context.Background(), v.input)
+			if !reflect.DeepEqual(got, v.expect) {
+				t.Errorf("miss match: \n\tinput:  %+v\n\texpect:%+v\n\tgot:   %+v", v.input, v.expect, got)
+			}
+			if !errors.Is(err, v.err) {
+				t.Errorf("error miss match: \n\texpect:%v\n\tgot:   %v", v.err, err)
+			}
+		})
+	}
+}
+
+// map排序遍历测试
+func Test_SortedMaps(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name   string
+		input  interface{}
+		less   []KeyLess
+		expect []string
+	}{
+		{
+			name:   "字符串key",
+			input:  map[string]interface{}{"b": 1, "a": map[string]int{"z": 1, "y": 2}, "c": 3},
+			expect: []string{"a.y", "a.z", "b", "c"},
+		},
+		{
+			name:   "数字key",
+			input:  map[int]int{10: 0, -1: 0, 3: 0, 2: 0},
+			expect: []string{"-1", "2", "3", "10"},
+		},
+		{
+			name:   "不同类型的key",
+			input:  map[interface{}]int{"b": 0, uint(3): 0, -2: 0, 2.5: 0, true: 0, false: 0, "a": 0},
+			expect: []string{"false", "true", "-2", "2.5", "3", "a", "b"},
+		},
+		{
+			name:   "NaN与大整数",
+			input:  map[interface{}]int{1.5: 0, math.NaN(): 0, int64(1<<53 + 1): 0, float64(1 << 53): 0, math.Inf(-1): 0, 1: 0},
+			expect: []string{"NaN", "-Inf", "1", "1.5", "9.007199254740992e+15", "9007199254740993"},
+		},
+		{
+			name:   "TextMarshaler key",
+			input:  map[time.Time]int{t1: 0, t2: 0},
+			expect: []string{t2.String(), t1.String()},
+		},
+		{
+			name:  "自定义排序",
+			input: map[string]int{"a": 0, "b": 0, "c": 0},
+			less: []KeyLess{func(a, b interface{}) bool {
+				return a.(string) > b.(string)
+			}},
+			expect: []string{"c", "b", "a"},
+		},
+		{
+			name:   "多个NaN key按值排序",
+			input:  map[float64]map[string]int{math.NaN(): {"c": 0}, 1: {"d": 0}, math.NaN(): {"a": 0}, math.NaN(): {"b": 0}},
+			expect: []string{"NaN.a", "NaN.b", "NaN.c", "1.d"},
+		},
+		{
+			name:  "自定义排序中相同的key按默认排序",
+			input: map[string]int{"b": 0, "a": 0, "A": 0},
+			less: []KeyLess{func(a, b interface{}) bool {
+				return strings.ToLower(a.(string)) < strings.ToLower(b.(string))
+			}},
+			expect: []string{"A", "a", "b"},
+		},
+	}
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			for i := 0; i < 10; i++ {
+				var got []string
+				NewTreeWalker(WithSortedMaps(v.less...), WithRoutine(func(ctx context.Context, node TreeNode) {
+					if reflect.ValueOf(node.Value().Interface()).Kind() != reflect.Map {
+						got = append(got, node.Path().String())
+					}
+				})).Walk(context.Background(), v.input)
+				if !reflect.DeepEqual(got, v.expect) {
+					t.Fatalf("miss match: \n\tinput:  %+v\n\texpect:%+v\n\tgot:   %+v", v.input, v.expect, got)
+				}
+			}
+		})
+	}
+}
+
+// 并发遍历测试
+func Test_Parallelism(t *testing.T) {
+	var input []interface{}
+	for i := 0; i < 200; i++ {
+		input = append(input, map[string]interface{}{
+			"id":   i,
+			"tags": []interface{}{"a", "b", i},
+			"sub":  map[string]interface{}{"n": i},
+		})
+	}
+	double := func(ctx context.Context, node TreeNode) {
+		if v, err := node.Value().Int(); err == nil {
+			node.Value().Set(v * 2)
+		}
+		if node.Type() == NodeType_map_pair && node.Key().MustString() == "tags" {
+			node.Insert("count", 3)
+		}
+		if s, err := node.Value().String(); err == nil && s == "a" {
+			node.Delete()
+			node.InsertAfter("A")
+		}
+	}
+
+	expect := NewTreeWalker(WithRoutine(double), WithLooseOverride()).Walk(context.Background(), input)
+
+	for _, n := range []int{2, 4, 16} {
+		t.Run(fmt.Sprintf("parallelism=%d", n), func(t *testing.T) {
+			var count int64
+			got, err := NewTreeWalker(WithRoutine(double, func(ctx context.Context, node TreeNode) {
+				atomic.AddInt64(&count, 1)
+			}), WithLooseOverride(), WithParallelism(n), WithMaxDepth(8)).WalkWithError(context.Background(), input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, expect) {
+				t.Errorf("miss match: \n\texpect:%+v\n\tgot:   %+v", expect, got)
+			}
+			if count != 200*6 {
+				t.Errorf("node count miss match: \n\texpect:%d\n\tgot:   %d", 200*6, count)
+			}
+		})
+	}
+
+	t.Run("取消", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		in := []int{1, 2, 3, 4}
+		got, err := NewTreeWalker(WithRoutine(double), WithParallelism(4)).WalkWithError(ctx, in)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("error miss match: \n\texpect:%v\n\tgot:   %v", context.Canceled, err)
+		}
+		if !reflect.DeepEqual(got, in) {
+			t.Errorf("miss match: \n\texpect:%+v\n\tgot:   %+v", in, got)
+		}
+	})
+
+	t.Run("遍历中取消", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		in := make([]int, 1000)
+		_, err := NewTreeWalker(WithRoutine(func(ctx context.Context, node TreeNode) {
+			cancel()
+		}), WithParallelism(4)).WalkWithError(ctx, in)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("error miss match: \n\texpect:%v\n\tgot:   %v", context.Canceled, err)
+		}
+	})
+
+	t.Run("错误", func(t *testing.T) {
+		_, err := NewTreeWalker(WithKeyTransform(SnakeCase), WithParallelism(4)).WalkWithError(context.Background(),
+			[]interface{}{map[string]int{"user_name": 1, "userName": 2}, map[string]int{"a": 1}})
+		if !errors.Is(err, ErrKeyCollision) {
+			t.Errorf("error miss match: \n\texpect:%v\n\tgot:   %v", ErrKeyCollision, err)
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("panic miss match: \n\texpect:%v\n\tgot:   %v", "boom", r)
+			}
+		}()
+		NewTreeWalker(WithRoutine(func(ctx context.Context, node TreeNode) {
+			if node.Value().MustInt() == 3 {
+				panic("boom")
+			}
+		}), WithParallelism(4)).Walk(context.Background(), []int{1, 2, 3, 4, 5, 6})
+	})
+}
+
+// 类型计划缓存测试
+func Test_Plan(t *testing.T) {
+	type Base struct{ ID int }
+	type record struct {
+		Base
+		Name    string `json:"name"`
+		Skip    string `json:"-"`
+		private int
+		When    time.Time
+		Audit   struct{ By string } `json:"audit,inline"`
+		Note    string              `json:"note,omitempty"`
+	}
+
+	tw := NewTreeWalker(WithTextMarshalerLeaves()).(*walker)
+	p := tw.plan(reflect.TypeOf(record{}))
+	if p != tw.plan(reflect.TypeOf(record{})) {
+		t.Errorf("plan not cached")
+	}
+
+	type fieldInfo struct {
+		Name      string
+		Exported  bool
+		Skip      bool
+		Inline    bool
+		OmitEmpty bool
+	}
+	var got []fieldInfo
+	for _, fp := range p.fields {
+		got = append(got, fieldInfo{fp.name, fp.exported, fp.skip, fp.inline, fp.omitEmpty})
+	}
+	expect := []fieldInfo{
+		{"Base", true, false, true, false},
+		{"name", true, false, false, false},
+		{"", true, true, false, false},
+		{"private", false, false, false, false},
+		{"When", true, false, false, false},
+		{"audit", true, false, true, false},
+		{"note", true, false, false, true},
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("miss match: \n\texpect:%+v\n\tgot:   %+v", expect, got)
+	}
+
+	if !tw.plan(reflect.TypeOf(time.Time{})).literal || tw.plan(reflect.TypeOf(record{})).literal {
+		t.Errorf("leaf type miss match")
+	}
+	// 不同选项的walker各自判断叶子类型
+	if NewTreeWalker().(*walker).plan(reflect.TypeOf(time.Time{})).literal {
+		t.Errorf("leaf type shared between walkers")
+	}
+}
+
+// 分配次数不随元素数增长
+func Test_Allocs(t *testing.T) {
+	m := make(map[string]interface{}, 1000)
+	s := make([]interface{}, 1000)
+	for i := 0; i < 1000; i++ {
+		m[fmt.Sprint(i)] = fmt.Sprint(i)
+		s[i] = fmt.Sprint(i)
+	}
+
+	testCases := []struct {
+		name  string
+		input interface{}
+		opts  []WalkOption
+		max   float64
+	}{
+		{name: "map无routine", input: m, max: 20},
+		{name: "slice无routine", input: s, max: 20},
+	}
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			tw := NewTreeWalker(v.opts...)
+			got := testing.AllocsPerRun(10, func() {
+				tw.Walk(context.Background(), v.input)
+			})
+			if got > v.max {
+				t.Errorf("allocs miss match: \n\texpect:<=%v\n\tgot:   %v", v.max, got)
+			}
+		})
+	}
+}
+
+// 最大递归深度测试
+func Test_MaxDepth(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    interface{}
+		jsonable bool
+		routines []Node_routine
+		expect   interface{}
+		maxDepth int
+	}{
+		{
+			name:     "不限制深度测试",
+			maxDepth: NoDepthLimit,
+			input: map[interface{}]interface{}{
+				"a": map[interface{}]interface{}{
+					"b": map[interface{}]interface{}{
+						"c": map[interface{}]interface{}{
+							"d": map[interface{}]interface{}{
+								"e": map[interface{}]interface{}{
+									"f": map[interface{}]interface{}{
+										"g": []interface{}{
+											"alice",
+											"bob",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expect: map[string]interface{}{
+				"A": map[string]interface{}{
+					"B": map[string]interface{}{
+						"C": map[string]interface{}{
+							"D": map[string]interface{}{
+								"E": map[string]interface{}{
+									"F": map[string]interface{}{
+										"G": []interface{}{
+											"ALICE",
+											"BOB",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			routines: []Node_routine{
+				func(ctx context.Context, node TreeNode) {
+					if node.Type() == NodeType_map_pair {
+						s := strings.ToUpper(node.Key().MustString())
+						node.Key().Set(s)
+					} else if node.Type() == NodeType_slice_member {
+						s, err := node.Value().String()
+						if err != nil {
+							return
+						}
+						s = strings.ToUpper(s)
+						node.Value().Set(s)
+					}
+				},
+			},
+		},
+		{
+			name:     "深度限制大于实际深度",
+			maxDepth: 10,
+			input: map[interface{}]interface{}{
+				"a": map[interface{}]interface{}{
+					"b": map[interface{}]interface{}{
+						"c": map[interface{}]interface{}{
+							"d": map[interface{}]interface{}{
+								"e": map[interface{}]interface{}{
+									"f": map[interface{}]interface{}{
+										"g": []interface{}{
+											"alice",
+											"bob",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expect: map[string]interface{}{
+				"A": map[string]interface{}{
+					"B": map[string]interface{}{
+						"C": map[string]interface{}{
+							"D": map[string]interface{}{
+								"E": map[string]interface{}{
+									"F": map[string]interface{}{
+										"G": []interface{}{
+											"ALICE",
+											"BOB",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			routines: []Node_routine{
+				func(ctx context.Context, node TreeNode) {
+					if node.Type() == NodeType_map_pair {
+						s := strings.ToUpper(node.Key().MustString())
+						node.Key().Set(s)
+					} else if node.Type() == NodeType_slice_member {
+						s, err := node.Value().String()
+						if err != nil {
+							return
+						}
+						s = strings.ToUpper(s)
+						node.Value().Set(s)
+					}
+				},
+			},
+		},
+		{
+			name:     "深度限制小于实际深度",
+			maxDepth: 5,
+			input: map[interface{}]interface{}{
+				"a": map[interface{}]interface{}{
+					"b": map[interface{}]interface{}{
+						"c": map[interface{}]interface{}{
+							"d": map[interface{}]interface{}{
+								"e": map[interface{}]interface{}{
+									"f": map[interface{}]interface{}{
+										"g": []interface{}{
+											"alice",
+											"bob",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expect: map[string]interface{}{ // 0
+				"A": map[string]interface{}{ // 1
+					"B": map[string]interface{}{ // 2
+						"C": map[string]interface{}{ // 3
+							"D": map[string]interface{}{ // 4
+								"E": map[string]interface{}{ // 5
+									"F": map[interface{}]interface{}{
+										"g": []interface{}{
+											"alice",
+											"bob",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			routines: []Node_routine{
+				func(ctx context.Context, node TreeNode) {
+					if node.Type() == NodeType_map_pair {
+						s := strings.ToUpper(node.Key().MustString())
+						node.Key().Set(s)
+					} else if node.Type() == NodeType_slice_member {
+						s, err := node.Value().String()
+						if err != nil {
+							return
+						}
+						s = strings.ToUpper(s)
+						node.Value().Set(s)
+					}
+				},
+			},
+		},
+		{
+			name:     "深度限制低于实际深度(两组map路径)",
+			maxDepth: 5,
+			input: map[interface{}]interface{}{ // 0
+				"a": map[interface{}]interface{}{ // 1
+					"b": map[interface{}]interface{}{ // 2
+						"c": map[interface{}]interface{}{ // 3
+							"d": map[interface{}]interface{}{ // 4
+								"e": map[interface{}]interface{}{
+									"f": map[interface{}]interface{}{
+										"g": []interface{}{
+											"alice",
+											"bob",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+				"b": map[interface{}]interface{}{
+					"c": map[interface{}]interface{}{
+						"d": map[interface{}]interface{}{
+							"e": map[interface{}]interface{}{
+								"f": map[interface{}]interface{}{
+									"g": []interface{}{
+										"alice",
+										"bob",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expect: map[string]interface{}{ // 0
+				"A": map[string]interface{}{ // 1
+					"B": map[string]interface{}{ // 2
+						"C": map[string]interface{}{ // 3
 							"D": map[string]interface{}{ // 4
 								"E": map[string]interface{}{ // 5
 									"F": map[interface{}]interface{}{
@@ -1233,3 +2710,332 @@ func Test_MaxDepth(t *testing.T) {
 // 		})
 // 	}
 // }
+
+func Test_DeepNesting(t *testing.T) {
+	// 栈上限远小于递归遍历所需，遍历深层嵌套的输入不应溢出
+	defer debug.SetMaxStack(debug.SetMaxStack(8 << 20))
+
+	const depth = 100000
+	type chain struct {
+		Next *chain
+		Name string
+	}
+
+	build := func(kind string) interface{} {
+		var v interface{} = "leaf"
+		for i := 0; i < depth; i++ {
+			switch kind {
+			case "slice":
+				v = []interface{}{v}
+			case "map":
+				v = map[string]interface{}{"k": v}
+			case "slice2":
+				v = []interface{}{v, "x"}
+			case "map2":
+				v = map[string]interface{}{"k": v, "x": "x"}
+			case "struct":
+				next, _ := v.(*chain)
+				v = &chain{Next: next, Name: "leaf"}
+			}
+		}
+		return v
+	}
+	// 取出最深处的值
+	bottom := func(v interface{}) (interface{}, int) {
+		n := 0
+		for {
+			switch c := v.(type) {
+			case []interface{}:
+				v = c[0]
+			case map[string]interface{}:
+				v = c["k"]
+			case *chain:
+				if c.Next == nil {
+					return c.Name, n
+				}
+				v = c.Next
+			default:
+				return v, n
+			}
+			n++
+		}
+	}
+
+	upper := func(ctx context.Context, node TreeNode) {
+		if s, ok := node.Value().Interface().(string); ok {
+			node.Value().Set(strings.ToUpper(s))
+		}
+	}
+
+	cases := []struct {
+		name    string
+		kind    string
+		options []WalkOption
+		expect  interface{}
+		levels  int
+	}{
+		{"slice无routine", "slice", nil, "leaf", depth},
+		{"slice", "slice", []WalkOption{WithRoutine(upper)}, "LEAF", depth},
+		{"map", "map", []WalkOption{WithRoutine(upper)}, "LEAF", depth},
+		{"struct指针", "struct", []WalkOption{WithRoutine(upper)}, "LEAF", depth - 1},
+		{"并发", "slice", []WalkOption{WithRoutine(upper), WithParallelism(4)}, "LEAF", depth},
+		{"并发slice每层两个成员", "slice2", []WalkOption{WithRoutine(upper), WithParallelism(4)}, "LEAF", depth},
+		{"并发map每层两个成员", "map2", []WalkOption{WithRoutine(upper), WithParallelism(4)}, "LEAF", depth},
+	}
+
+	for _, c := range cases {
+		out := NewTreeWalker(c.options...).Walk(context.Background(), build(c.kind))
+		got, n := bottom(out)
+		if got != c.expect || n != c.levels {
+			t.Errorf("miss match: \n\tinput: %s\n\texpect:%v at %d\n\tgot:   %v at %d", c.name, c.expect, c.levels, got, n)
+		}
+	}
+
+	// 路径完整记录每一层
+	var pathLen int
+	NewTreeWalker(WithRoutine(func(ctx context.Context, node TreeNode) {
+		if node.Type() == NodeType_slice_member {
+			if _, ok := node.Value().Interface().(string); ok {
+				pathLen = len(node.Path())
+			}
+		}
+	})).Walk(context.Background(), build("slice"))
+	if pathLen != depth {
+		t.Errorf("miss match: \n\tinput: path\n\texpect:%d\n\tgot:   %d", depth, pathLen)
+	}
+}
+
+func Test_Depth(t *testing.T) {
+	input := map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": []interface{}{2}}}
+
+	for _, maxDepth := range []int{NoDepthLimit, 8} {
+		got := map[string]string{}
+		NewTreeWalker(WithMaxDepth(maxDepth), WithRoutine(func(ctx context.Context, node TreeNode) {
+			fromCtx := "-"
+			if d, ok := ctx.Value(DepthCtxKey).(*int); ok {
+				fromCtx = fmt.Sprint(*d)
+			}
+			got[node.Path().String()] = fmt.Sprintf("%d/%s", node.Depth(), fromCtx)
+		})).Walk(context.Background(), input)
+
+		// 未限制深度时ctx中没有深度
+		ctxDepth := func(d string) string {
+			if maxDepth == NoDepthLimit {
+				return "-"
+			}
+			return d
+		}
+		expect := map[string]string{
+			"a":     "0/" + ctxDepth("0"),
+			"b":     "0/" + ctxDepth("0"),
+			"b.c":   "1/" + ctxDepth("1"),
+			"b.c.0": "2/" + ctxDepth("2"),
+		}
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("miss match: \n\tinput: maxDepth=%d\n\texpect:%v\n\tgot:   %v", maxDepth, expect, got)
+		}
+	}
+}
+
+func Test_Cursor(t *testing.T) {
+	input := func() interface{} {
+		return map[string]interface{}{
+			"name": "svc",
+			"tags": []interface{}{"a", "b"},
+			"db":   map[string]interface{}{"host": "h", "port": 5432},
+		}
+	}
+
+	// 按顺序取出所有节点及其路径
+	var paths []string
+	c := Nodes(context.Background(), input(), WithSortedMaps())
+	for c.Next() {
+		paths = append(paths, fmt.Sprintf("%v=%v", c.Path(), c.Node().Value().Interface()))
+	}
+	if c.Node() != nil || c.Next() {
+		t.Errorf("cursor not finished")
+	}
+	expect := []string{"db.host=h", "db.port=5432", "name=svc", "tags.0=a", "tags.1=b"}
+	var got []string
+	for _, p := range paths {
+		if !strings.HasPrefix(p, "db=") && !strings.HasPrefix(p, "tags=") {
+			got = append(got, p)
+		}
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("miss match: \n\tinput: paths\n\texpect:%v\n\tgot:   %v", expect, got)
+	}
+
+	testCases := []struct {
+		name   string
+		visit  func(c *NodeCursor) bool // 返回false时提前结束
+		opts   []WalkOption
+		expect interface{}
+	}{
+		{
+			"修改与删除",
+			func(c *NodeCursor) bool {
+				if s, ok := c.Node().Value().Interface().(string); ok {
+					if s == "b" {
+						c.Node().Delete()
+					} else {
+						c.Node().Value().Set(strings.ToUpper(s))
+					}
+				}
+				return true
+			},
+			nil,
+			map[string]interface{}{
+				"name": "SVC",
+				"tags": []interface{}{"A"},
+				"db":   map[string]interface{}{"host": "H", "port": 5432},
+			},
+		},
+		{
+			"提前结束",
+			func(c *NodeCursor) bool {
+				c.Node().Value().Set("x")
+				return false
+			},
+			[]WalkOption{WithSortedMaps()},
+			map[string]interface{}{
+				"name": "svc",
+				"tags": []interface{}{"a", "b"},
+				"db":   map[string]interface{}{"host": "x", "port": 5432},
+			},
+		},
+		{
+			"routine先处理",
+			func(c *NodeCursor) bool {
+				if c.Path().String() == "db" {
+					t.Errorf("deleted node yielded")
+				}
+				if c.Path().String() == "tags.1" {
+					c.Node().Value().Set("c")
+				}
+				return true
+			},
+			[]WalkOption{WithRoutine(func(ctx context.Context, node TreeNode) {
+				if node.Path().String() == "db" {
+					node.Delete()
+				}
+			})},
+			map[string]interface{}{
+				"name": "svc",
+				"tags": []interface{}{"a", "c"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		c := Nodes(context.Background(), input(), tc.opts...)
+		for c.Next() {
+			if !tc.visit(c) {
+				break
+			}
+		}
+		got, err := c.Close()
+		if err != nil || !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("miss match: \n\tinput: %s\n\texpect:%v\n\tgot:   %v %v", tc.name, tc.expect, got, err)
+		}
+	}
+
+	if out, err := Nodes(context.Background(), nil).Close(); out != nil || err != nil {
+		t.Errorf("miss match: \n\tinput: nil\n\texpect:<nil>\n\tgot:   %v %v", out, err)
+	}
+}
+
+func Test_Limits(t *testing.T) {
+	input := func() interface{} {
+		return map[string]interface{}{
+			"name":   "héllo wörld",
+			"nested": map[string]interface{}{"x": "yyyy"},
+			"tags":   []interface{}{"a", "b", "c", "d"},
+		}
+	}
+	type record struct {
+		Name string
+		Tags []string
+	}
+
+	testCases := []struct {
+		name   string
+		input  interface{}
+		limits Limits
+		expect interface{}
+		limit  string // 超出的限制，为空时不应出错
+		path   string
+	}{
+		{
+			"成员数停止", input(), Limits{MaxElements: 3}, nil, "MaxElements", "tags",
+		},
+		{
+			"成员数截断", input(), Limits{MaxElements: 3, Truncate: true},
+			map[string]interface{}{
+				"name":   "héllo wörld",
+				"nested": map[string]interface{}{"x": "yyyy"},
+				"tags":   []interface{}{"a", "b", "c"},
+			}, "", "",
+		},
+		{
+			"字符串停止", input(), Limits{MaxString: 4}, nil, "MaxString", "name",
+		},
+		{
+			"字符串截断", input(), Limits{MaxString: 4, Truncate: true},
+			map[string]interface{}{
+				"name": "hél",
+				"tags": []interface{}{"a", "b", "c", "d"},
+			}, "", "",
+		},
+		{
+			"节点数停止", input(), Limits{MaxNodes: 3}, nil, "MaxNodes", "tags",
+		},
+		{
+			"节点数截断", input(), Limits{MaxNodes: 3, Truncate: true},
+			map[string]interface{}{
+				"name":   "héllo wörld",
+				"nested": map[string]interface{}{"x": "yyyy"},
+			}, "", "",
+		},
+		{
+			"总字节数截断", input(), Limits{MaxBytes: 5, Truncate: true},
+			map[string]interface{}{"name": "h"}, "", "",
+		},
+		{
+			"总字节数停止", input(), Limits{MaxBytes: 25}, nil, "MaxBytes", "nested.x",
+		},
+		{
+			"struct原地截断不拆分字符", &record{Name: "héllo", Tags: []string{"x", "y", "z"}}, Limits{MaxString: 2, MaxElements: 2, Truncate: true},
+			&record{Name: "h", Tags: []string{"x", "y"}}, "", "",
+		},
+		{
+			"未超出", input(), Limits{MaxNodes: 100, MaxElements: 4, MaxString: 13, MaxBytes: 100}, input(), "", "",
+		},
+	}
+
+	for _, tc := range testCases {
+		out, err := NewTreeWalker(WithLimits(tc.limits), WithSortedMaps()).WalkWithError(context.Background(), tc.input)
+		if tc.limit == "" {
+			if err != nil || !reflect.DeepEqual(out, tc.expect) {
+				t.Errorf("miss match: \n\tinput: %s\n\texpect:%v\n\tgot:   %v %v", tc.name, tc.expect, out, err)
+			}
+			continue
+		}
+
+		var le *LimitError
+		var pe *PathError
+		if !errors.Is(err, ErrLimitExceeded) || !errors.As(err, &le) || !errors.As(err, &pe) ||
+			le.Limit != tc.limit || pe.Path.String() != tc.path {
+			t.Errorf("miss match: \n\tinput: %s\n\texpect:%s at %s\n\tgot:   %v", tc.name, tc.limit, tc.path, err)
+		}
+	}
+
+	// 超出限制后停止遍历，剩余成员不再交给routine
+	var visited int
+	_, err := NewTreeWalker(WithLimits(Limits{MaxNodes: 10}), WithRoutine(func(ctx context.Context, node TreeNode) {
+		visited++
+	})).WalkWithError(context.Background(), make([]interface{}, 1000))
+	if err == nil || visited != 10 {
+		t.Errorf("miss match: \n\tinput: stop\n\texpect:10\n\tgot:   %d %v", visited, err)
+	}
+}